@@ -0,0 +1,540 @@
+// Package gif2vid combines GIF, animated WebP, APNG and short video files into
+// a single H.264 MP4, using ffmpeg and ffprobe (and optionally ImageMagick)
+// under the hood.
+//
+// It is the library behind the gif2vid command:
+//
+//	b := gif2vid.New(gif2vid.Options{FPS: 60, Profile: "web"})
+//	b.Events = gif2vid.EventHandlerFunc(func(e gif2vid.Event) { log.Println(e.Kind, e.Input) })
+//	err := b.Build(ctx, []string{"a.gif", "b.webp"}, "out.mp4")
+package gif2vid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/notify"
+	"github.com/crit/gif2vid/internal/pipeline"
+	"github.com/crit/gif2vid/internal/report"
+	"github.com/crit/gif2vid/internal/util"
+)
+
+// Options configures a build. Zero values mean "use the default", or the
+// profile's value when Profile is set; non-zero values override the profile.
+type Options struct {
+	FPS         int     // frames per second (default 30)
+	CRF         int     // x264/x265 quality, 1–51 (default 23); -1 for lossless (CRF 0)
+	Preset      string  // encoder preset (default "medium")
+	Background  string  // padding color, name or #RRGGBB (default "black")
+	Profile     string  // named profile such as "web" or "twitter"
+	Width       int     // canvas width; 0 uses the widest input
+	Height      int     // canvas height; 0 uses the tallest input
+	Codec       string  // "libx264" (default) or "libx265"
+	Bitrate     string  // target bitrate such as "5M"; replaces CRF
+	MaxDuration float64 // output length limit in seconds; 0 is unlimited
+	Manifest    string  // JSON file with per-clip settings such as captions
+	Chapters    bool    // embed one chapter per input
+	Subtitles   string  // write a .vtt or .srt file captioning each input
+	EmbedSubs   bool    // embed the captions as a mov_text subtitle track
+	Text        Text    // burned-in captions, watermark text and timecode
+	Watermark   Logo    // image drawn over the whole output
+	Cards       Cards   // title card before the inputs, end card after
+	Audio       Audio   // background music, clip sound or a silent track
+	Stills      Stills  // how PNG/JPEG inputs are shown
+	Effects     Effects // trim, speed, reverse and boomerang for every input
+	Grid        Grid    // layout for Builder.Grid
+	Compare     Compare // layout for Builder.Compare
+	Inset       Inset   // layout for Builder.PictureInPicture
+	Split       Split   // write the output as numbered parts
+	Notify      Notify  // webhook told the outcome of every build
+	Overwrite   bool    // replace an existing output file
+	KeepTemp    bool    // keep the temp workspace (reported via EventTempKept)
+	TmpDir      string  // directory each run creates its temp workspace in; default os.TempDir()
+	Concurrency int     // parallel segment encodes; default runtime.NumCPU()
+	// MagickBin is the ImageMagick binary for fallbacks ("magick" or
+	// "convert"). When Runner is nil it is detected on PATH if empty.
+	MagickBin string
+}
+
+// Text configures burned-in text. Zero values use the defaults: a bundled or
+// system font at 24px in white, captions at the bottom, watermark text at the
+// top right and the timecode at the top left.
+type Text struct {
+	Captions          bool   // each clip's caption: manifest, sidecar .txt, or filename
+	Watermark         string // text drawn on every frame
+	Timecode          bool   // running output time, HH:MM:SS.mmm
+	FontFile          string // TrueType font; required when none can be found
+	FontSize          int
+	FontColor         string // name, #RRGGBB, or color@alpha
+	Box               bool   // translucent box behind the text
+	CaptionPosition   string // top-left, top, top-right, center, bottom-left, bottom, bottom-right
+	WatermarkPosition string
+	TimecodePosition  string
+}
+
+// Logo is a watermark image drawn once over the joined video, so it does not
+// flicker at cuts. Zero values use the defaults: top right, 16px margin, 15%
+// of the canvas width, opaque.
+type Logo struct {
+	Path     string  // PNG or other image ffmpeg can read; empty disables it
+	Position string  // as for Text; must differ from Text.WatermarkPosition when both are drawn
+	Margin   int     // pixels from the frame edge
+	Scale    float64 // width as a fraction of the canvas; negative keeps the image size
+	Opacity  float64 // 0–1
+}
+
+// Cards are generated title and end cards. A card is added when it has text
+// or an image. Zero values use the defaults: 3 seconds, black background, 48px
+// text in Text.FontColor.
+type Cards struct {
+	Intro      string // title card text
+	IntroImage string // title card background, fitted to the canvas
+	Outro      string // end card text
+	OutroImage string // end card background, fitted to the canvas
+	Duration   float64
+	Color      string // background color when there is no image
+	FontSize   int
+}
+
+// Audio adds a soundtrack; outputs are silent by default. Zero values use the
+// defaults: full volume, no fades, AAC at 192k.
+type Audio struct {
+	Path    string  // background audio, padded with silence or trimmed to the video
+	Loop    bool    // loop Path when it is shorter than the video
+	FadeIn  float64 // seconds
+	FadeOut float64 // seconds
+	Volume  float64 // multiplier
+	Codec   string  // "aac" or "opus"
+	Bitrate string  // such as "192k"
+	PerClip bool    // keep the sound of inputs that have it, mixed under Path
+	Silent  bool    // add a silent stereo track; excludes Path and PerClip
+}
+
+// Stills configures still image inputs (PNG and JPEG; APNGs are animated).
+// Zero values use the defaults: 3 seconds, no motion. A manifest can set
+// both per file.
+type Stills struct {
+	Duration float64 // seconds each still is shown
+	Motion   string  // "none", "zoom-in", "zoom-out", "pan-left" or "pan-right"
+}
+
+// Effects are trims and playback transforms applied to every animated input;
+// a manifest can override each per file. A zero Speed plays at normal speed.
+type Effects struct {
+	Speed     float64 // 0.5 to 4
+	Reverse   bool    // play backwards
+	Boomerang bool    // play forwards, then backwards
+	TrimStart string  // skip to this point: seconds ("1.5") or a frame ("30f")
+	TrimEnd   string  // cut at this point; empty keeps the rest
+}
+
+// Grid configures Builder.Grid. Cells are the canvas size (Width and Height,
+// or the largest input). Zero values use the defaults: a square-ish grid,
+// an 8px gutter, and the longest clip's length.
+type Grid struct {
+	Cols     int     // columns
+	Gutter   int     // even number of pixels between cells
+	Duration float64 // seconds; shorter clips loop
+}
+
+// Compare configures Builder.Compare. By default pairs are side by side and
+// each side is labeled with the name of its files' directory.
+type Compare struct {
+	Vertical bool   // stack pairs top and bottom
+	NoLabels bool   // don't label the sides
+	LabelA   string // label of the first side
+	LabelB   string // label of the second side
+}
+
+// Inset configures Builder.PictureInPicture. Zero values use the defaults:
+// the bottom-right corner, 30% of the canvas width, 16px from the edges, a
+// 4px white border, and hidden once the inset sequence ends.
+type Inset struct {
+	Position    string  // "top-left", "top-right", "bottom-left", "bottom-right", ...
+	Scale       float64 // width as a fraction of the canvas width
+	Margin      int     // pixels from the frame edges
+	Border      int     // pixels; -1 for none
+	BorderColor string
+	Loop        bool // loop the inset sequence until the main one ends
+}
+
+// Split writes a build as out_001.mp4, out_002.mp4, ... instead of out.mp4,
+// breaking between clips. Zero values are no limit; with none set the output
+// is a single file.
+type Split struct {
+	MaxDuration float64 // seconds per part
+	MaxSize     int64   // approximate bytes per part
+	Every       int     // inputs per part
+	AllowCut    bool    // cut a clip over a limit on its own, instead of failing
+}
+
+// Notify POSTs the JSON run report, the same as gif2vid --report writes, to
+// URL when a build finishes or fails. A failed delivery is retried with a
+// doubling backoff from one second; if it still fails, the build returns the
+// delivery error even though the output was written.
+type Notify struct {
+	URL     string
+	Secret  string // signs the body with HMAC-SHA256 in the X-Gif2vid-Signature header
+	Retries int    // default 3; -1 for none
+}
+
+// Runner executes external commands. Replace it to run ffmpeg remotely, in a
+// container, or to fake it in tests.
+type Runner interface {
+	Run(ctx context.Context, name string, args []string) (stdout, stderr []byte, err error)
+}
+
+// EventKind identifies a progress step.
+type EventKind string
+
+const (
+	EventProbed         EventKind = EventKind(pipeline.EventProbed)
+	EventSegmentStarted EventKind = EventKind(pipeline.EventSegmentStarted)
+	EventSegmentDone    EventKind = EventKind(pipeline.EventSegmentDone)
+	EventFallback       EventKind = EventKind(pipeline.EventFallback)
+	EventSegmentFailed  EventKind = EventKind(pipeline.EventSegmentFailed)
+	EventConcatStarted  EventKind = EventKind(pipeline.EventConcatStarted)
+	EventTempKept       EventKind = EventKind(pipeline.EventTempKept)
+	EventDone           EventKind = EventKind(pipeline.EventDone)
+	EventError          EventKind = "error" // the build failed; Err is what Build returns
+)
+
+// Event describes a progress step or error. Index is the input's position in
+// the inputs passed to Build and Total their count.
+type Event struct {
+	Kind   EventKind
+	Index  int
+	Total  int
+	Input  string
+	Path   string // segment, output or workspace path, depending on Kind
+	Width  int
+	Height int
+	Err    error
+}
+
+// EventHandler receives events during a build. Segment events are delivered
+// from worker goroutines, so implementations must be safe for concurrent use.
+type EventHandler interface {
+	HandleEvent(Event)
+}
+
+// EventHandlerFunc adapts a function to EventHandler.
+type EventHandlerFunc func(Event)
+
+func (f EventHandlerFunc) HandleEvent(e Event) { f(e) }
+
+// Result describes a finished or failed build.
+type Result struct {
+	Output   string        // the output path
+	Parts    []string      // the files of a split output; empty otherwise
+	Duration float64       // seconds
+	Size     int64         // bytes, across all parts
+	Inputs   []InputResult // in build order
+	Err      error         // nil when the build succeeded
+}
+
+// InputResult is the outcome of one input of a build.
+type InputResult struct {
+	Path     string
+	Decoder  string  // "ffmpeg", or "imagemagick" after a fallback; empty if not encoded
+	Start    float64 // offset in the output, in seconds
+	Duration float64 // seconds
+	Error    string  // why the input failed; empty if it didn't
+}
+
+// Hook is told the outcome of every build, after its last event.
+type Hook interface {
+	BuildFinished(ctx context.Context, r Result)
+}
+
+// HookFunc adapts a function to Hook.
+type HookFunc func(context.Context, Result)
+
+func (f HookFunc) BuildFinished(ctx context.Context, r Result) { f(ctx, r) }
+
+// Builder runs builds with fixed options. It is safe to reuse, but not to
+// change its fields while a build is running.
+type Builder struct {
+	Options Options
+	Runner  Runner       // nil runs ffmpeg/ffprobe from PATH
+	Events  EventHandler // may be nil
+	Hook    Hook         // may be nil
+}
+
+// New returns a Builder for opts.
+func New(opts Options) *Builder {
+	return &Builder{Options: opts}
+}
+
+// Build combines inputs, in order, into output.
+func (b *Builder) Build(ctx context.Context, inputs []string, output string) error {
+	return b.run(ctx, inputs, output, pipeline.Run)
+}
+
+// Grid tiles inputs, in rows, on one canvas in output, all playing at once.
+// It needs at least two inputs.
+func (b *Builder) Grid(ctx context.Context, inputs []string, output string) error {
+	return b.run(ctx, inputs, output, pipeline.RunGrid)
+}
+
+// Compare pairs the files in a and b with the same name and plays each pair
+// side by side, one pair after another, in output. It returns the files that
+// had no counterpart; they are left out.
+func (b *Builder) Compare(ctx context.Context, a, bs []string, output string) (unmatched []string, err error) {
+	var compare []string
+	for _, p := range bs {
+		abs, err := util.AbsClean(p)
+		if err != nil {
+			return nil, err
+		}
+		compare = append(compare, abs)
+	}
+	err = b.run(ctx, a, output, func(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs pipeline.Observer) (*pipeline.Result, error) {
+		cfg.CompareInputs = compare
+		cfg.InputDir = filepath.Dir(cfg.Inputs[0])
+		if len(compare) > 0 {
+			cfg.CompareDir = filepath.Dir(compare[0])
+		}
+		res, err := pipeline.RunCompare(ctx, r, cfg, obs)
+		if res != nil {
+			unmatched = res.Unmatched
+		}
+		return res, err
+	})
+	return unmatched, err
+}
+
+// PictureInPicture builds main like Build and plays inset, in order and at
+// its own size, as a scaled inset over it in output.
+func (b *Builder) PictureInPicture(ctx context.Context, main, inset []string, output string) error {
+	var abs []string
+	for _, p := range inset {
+		a, err := util.AbsClean(p)
+		if err != nil {
+			return err
+		}
+		abs = append(abs, a)
+	}
+	return b.run(ctx, main, output, func(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs pipeline.Observer) (*pipeline.Result, error) {
+		cfg.InsetInputs = abs
+		return pipeline.RunPIP(ctx, r, cfg, obs)
+	})
+}
+
+type mode func(context.Context, ffmpeg.Runner, *config.Config, pipeline.Observer) (*pipeline.Result, error)
+
+func (b *Builder) run(ctx context.Context, inputs []string, output string, m mode) error {
+	started := time.Now()
+	cfg, r, res, err := b.build(ctx, inputs, output, m)
+	if cfg != nil && (b.Hook != nil || cfg.NotifyURL != "") {
+		// Canceling ctx stops the build, not the report of it.
+		ctx, cancel := notify.Detached(ctx)
+		defer cancel()
+		version := ""
+		if cfg.NotifyURL != "" && r != nil {
+			version, _ = ffmpeg.Version(ctx, r, "ffmpeg")
+		}
+		rep := report.New(cfg, res, err, version, started)
+		if b.Hook != nil {
+			b.Hook.BuildFinished(ctx, newResult(rep, err))
+		}
+		if hook := notify.New(cfg); hook != nil {
+			if nerr := hook.Send(ctx, rep); nerr != nil && err == nil {
+				err = nerr
+			}
+		}
+	} else if b.Hook != nil {
+		b.Hook.BuildFinished(ctx, Result{Output: output, Err: err})
+	}
+	if err != nil && b.Events != nil {
+		b.Events.HandleEvent(Event{Kind: EventError, Total: len(inputs), Err: err})
+	}
+	return err
+}
+
+// build runs m. It returns the configuration and runner once they are known,
+// even when the build fails after that.
+func (b *Builder) build(ctx context.Context, inputs []string, output string, m mode) (*config.Config, ffmpeg.Runner, *pipeline.Result, error) {
+	if len(inputs) == 0 {
+		return nil, nil, nil, errors.New("no inputs")
+	}
+	cfg, err := b.config()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cfg.Output, err = util.AbsClean(output); err != nil {
+		return nil, nil, nil, err
+	}
+	for _, in := range inputs {
+		p, err := util.AbsClean(in)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cfg.Inputs = append(cfg.Inputs, p)
+	}
+
+	var r ffmpeg.Runner = b.Runner
+	if b.Runner == nil {
+		for _, bin := range []string{"ffmpeg", "ffprobe"} {
+			if _, err := ffmpeg.LookPath(bin); err != nil {
+				return cfg, nil, nil, err
+			}
+		}
+		if cfg.MagickBin == "" {
+			cfg.MagickBin = ffmpeg.FindMagick()
+		}
+		r = ffmpeg.ExecRunner{}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
+		return cfg, r, nil, err
+	}
+	res, err := m(ctx, r, cfg, b.observer())
+	return cfg, r, res, err
+}
+
+// newResult converts a run report to a Result.
+func newResult(rep *report.Report, err error) Result {
+	r := Result{
+		Output:   rep.Output,
+		Parts:    rep.Parts,
+		Duration: rep.Duration,
+		Size:     rep.OutputSize,
+		Err:      err,
+	}
+	for _, in := range rep.Inputs {
+		r.Inputs = append(r.Inputs, InputResult{
+			Path:     in.Path,
+			Decoder:  in.Decoder,
+			Start:    in.Start,
+			Duration: in.Duration,
+			Error:    in.Error,
+		})
+	}
+	return r
+}
+
+// config translates Options into the internal configuration.
+func (b *Builder) config() (*config.Config, error) {
+	o := b.Options
+	cfg := config.New()
+	set := func(name string, ok bool, apply func()) {
+		if ok {
+			apply()
+			cfg.Sources[name] = config.SourceOption
+		}
+	}
+	set("fps", o.FPS != 0, func() { cfg.FPS = o.FPS })
+	set("crf", o.CRF != 0, func() { cfg.CRF = max(o.CRF, 0) })
+	set("preset", o.Preset != "", func() { cfg.Preset = o.Preset })
+	set("bg", o.Background != "", func() { cfg.BG = o.Background })
+	set("width", o.Width != 0, func() { cfg.Width = o.Width })
+	set("height", o.Height != 0, func() { cfg.Height = o.Height })
+	set("codec", o.Codec != "", func() { cfg.Codec = o.Codec })
+	set("bitrate", o.Bitrate != "", func() { cfg.Bitrate = o.Bitrate })
+	set("max-duration", o.MaxDuration != 0, func() { cfg.MaxDuration = o.MaxDuration })
+	set("font-file", o.Text.FontFile != "", func() { cfg.FontFile = o.Text.FontFile })
+	set("font-size", o.Text.FontSize != 0, func() { cfg.FontSize = o.Text.FontSize })
+	set("font-color", o.Text.FontColor != "", func() { cfg.FontColor = o.Text.FontColor })
+	set("caption-position", o.Text.CaptionPosition != "", func() { cfg.CaptionPos = o.Text.CaptionPosition })
+	set("watermark-position", o.Text.WatermarkPosition != "", func() { cfg.WatermarkPos = o.Text.WatermarkPosition })
+	set("timecode-position", o.Text.TimecodePosition != "", func() { cfg.TimecodePos = o.Text.TimecodePosition })
+	set("watermark-image-position", o.Watermark.Position != "", func() { cfg.WatermarkImagePos = o.Watermark.Position })
+	set("watermark-margin", o.Watermark.Margin != 0, func() { cfg.WatermarkMargin = o.Watermark.Margin })
+	set("watermark-scale", o.Watermark.Scale != 0, func() { cfg.WatermarkScale = max(o.Watermark.Scale, 0) })
+	set("watermark-opacity", o.Watermark.Opacity != 0, func() { cfg.WatermarkOpacity = o.Watermark.Opacity })
+	set("card-duration", o.Cards.Duration != 0, func() { cfg.CardDuration = o.Cards.Duration })
+	set("card-color", o.Cards.Color != "", func() { cfg.CardColor = o.Cards.Color })
+	set("card-font-size", o.Cards.FontSize != 0, func() { cfg.CardFontSize = o.Cards.FontSize })
+	set("audio-fade-in", o.Audio.FadeIn != 0, func() { cfg.AudioFadeIn = o.Audio.FadeIn })
+	set("audio-fade-out", o.Audio.FadeOut != 0, func() { cfg.AudioFadeOut = o.Audio.FadeOut })
+	set("audio-volume", o.Audio.Volume != 0, func() { cfg.AudioVolume = o.Audio.Volume })
+	set("audio-codec", o.Audio.Codec != "", func() { cfg.AudioCodec = o.Audio.Codec })
+	set("audio-bitrate", o.Audio.Bitrate != "", func() { cfg.AudioBitrate = o.Audio.Bitrate })
+	set("still-duration", o.Stills.Duration != 0, func() { cfg.StillDur = o.Stills.Duration })
+	set("still-motion", o.Stills.Motion != "", func() { cfg.StillMotion = o.Stills.Motion })
+	set("speed", o.Effects.Speed != 0, func() { cfg.Speed = o.Effects.Speed })
+	set("stack", o.Compare.Vertical, func() { cfg.Stack = "vertical" })
+	set("labels", o.Compare.NoLabels, func() { cfg.Labels = false })
+	set("label-a", o.Compare.LabelA != "", func() { cfg.LabelA = o.Compare.LabelA })
+	set("label-b", o.Compare.LabelB != "", func() { cfg.LabelB = o.Compare.LabelB })
+	set("inset-position", o.Inset.Position != "", func() { cfg.InsetPos = o.Inset.Position })
+	set("inset-scale", o.Inset.Scale != 0, func() { cfg.InsetScale = o.Inset.Scale })
+	set("inset-margin", o.Inset.Margin != 0, func() { cfg.InsetMargin = o.Inset.Margin })
+	set("inset-border", o.Inset.Border != 0, func() { cfg.InsetBorder = max(o.Inset.Border, 0) })
+	set("inset-border-color", o.Inset.BorderColor != "", func() { cfg.InsetBorderColor = o.Inset.BorderColor })
+	set("inset-timing", o.Inset.Loop, func() { cfg.InsetTiming = "loop" })
+	set("split-max-duration", o.Split.MaxDuration != 0, func() { cfg.SplitMaxDuration = o.Split.MaxDuration })
+	set("split-max-size", o.Split.MaxSize != 0, func() { cfg.SplitMaxSize = config.ByteSize(o.Split.MaxSize) })
+	set("split-every", o.Split.Every != 0, func() { cfg.SplitEvery = o.Split.Every })
+	set("cols", o.Grid.Cols != 0, func() { cfg.Cols = o.Grid.Cols })
+	set("gutter", o.Grid.Gutter != 0, func() { cfg.Gutter = o.Grid.Gutter })
+	set("grid-duration", o.Grid.Duration != 0, func() { cfg.GridDuration = o.Grid.Duration })
+	set("notify-url", o.Notify.URL != "", func() { cfg.NotifyURL = o.Notify.URL })
+	set("notify-secret", o.Notify.Secret != "", func() { cfg.NotifySecret = o.Notify.Secret })
+	set("notify-retries", o.Notify.Retries != 0, func() { cfg.NotifyRetries = max(o.Notify.Retries, 0) })
+	set("tmp-dir", o.TmpDir != "", func() { cfg.TmpDir = o.TmpDir })
+	set("concurrency", o.Concurrency != 0, func() { cfg.Concurrency = o.Concurrency })
+	cfg.Profile = o.Profile
+	cfg.Manifest = o.Manifest
+	cfg.Chapters = o.Chapters
+	cfg.Subtitles = o.Subtitles
+	cfg.EmbedSubs = o.EmbedSubs
+	cfg.Captions = o.Text.Captions
+	cfg.WatermarkText = o.Text.Watermark
+	cfg.Timecode = o.Text.Timecode
+	cfg.TextBox = o.Text.Box
+	cfg.Watermark = o.Watermark.Path
+	cfg.Intro = o.Cards.Intro
+	cfg.IntroImage = o.Cards.IntroImage
+	cfg.Outro = o.Cards.Outro
+	cfg.OutroImage = o.Cards.OutroImage
+	cfg.Audio = o.Audio.Path
+	cfg.AudioLoop = o.Audio.Loop
+	cfg.AudioPerClip = o.Audio.PerClip
+	cfg.SilentAudio = o.Audio.Silent
+	cfg.Reverse = o.Effects.Reverse
+	cfg.Boomerang = o.Effects.Boomerang
+	cfg.SplitAllowCut = o.Split.AllowCut
+	if err := cfg.TrimStart.Set(o.Effects.TrimStart); err != nil {
+		return nil, fmt.Errorf("trim start: %w", err)
+	}
+	if err := cfg.TrimEnd.Set(o.Effects.TrimEnd); err != nil {
+		return nil, fmt.Errorf("trim end: %w", err)
+	}
+	cfg.Overwrite = o.Overwrite
+	cfg.KeepTemp = o.KeepTemp
+	cfg.MagickBin = o.MagickBin
+
+	if err := cfg.ApplyProfile(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (b *Builder) observer() pipeline.Observer {
+	if b.Events == nil {
+		return nil
+	}
+	return pipeline.ObserverFunc(func(e pipeline.Event) {
+		b.Events.HandleEvent(Event{
+			Kind:   EventKind(e.Kind),
+			Index:  e.Index,
+			Total:  e.Total,
+			Input:  e.Input,
+			Path:   e.Path,
+			Width:  e.Width,
+			Height: e.Height,
+			Err:    e.Err,
+		})
+	})
+}
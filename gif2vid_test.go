@@ -0,0 +1,218 @@
+package gif2vid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crit/gif2vid/internal/notify"
+)
+
+// fakeRunner answers ffprobe with fixed dimensions and a 1.5s duration and
+// makes ffmpeg create its output file (the last argument).
+type fakeRunner struct {
+	mu    sync.Mutex
+	calls [][]string
+	fail  string // ffmpeg fails when any argument contains this
+}
+
+func (f *fakeRunner) Run(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, append([]string{name}, args...))
+	f.mu.Unlock()
+	switch name {
+	case "ffprobe":
+		return []byte(`{"streams":[{"codec_type":"video","width":101,"height":50}],"format":{"duration":"1.500000"}}`), nil, nil
+	case "ffmpeg":
+		if slices.Equal(args, []string{"-version"}) {
+			return []byte("ffmpeg version 7.1-test\n"), nil, nil
+		}
+		for _, a := range args {
+			if f.fail != "" && strings.Contains(a, f.fail) {
+				return nil, []byte("boom"), errors.New("exit status 1")
+			}
+		}
+		return nil, nil, os.WriteFile(args[len(args)-1], []byte("mp4"), 0o644)
+	}
+	return nil, nil, errors.New("unexpected command " + name)
+}
+
+func (f *fakeRunner) ffmpegCalls() [][]string {
+	var out [][]string
+	for _, c := range f.calls {
+		if c[0] == "ffmpeg" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestBuild(t *testing.T) {
+	tmp := t.TempDir()
+	out := filepath.Join(tmp, "out", "result.mp4")
+	fr := &fakeRunner{}
+
+	var mu sync.Mutex
+	var kinds []EventKind
+	b := New(Options{FPS: 12, TmpDir: filepath.Join(tmp, "work"), Concurrency: 2})
+	b.Runner = fr
+	b.Events = EventHandlerFunc(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		kinds = append(kinds, e.Kind)
+	})
+
+	if err := b.Build(context.Background(), []string{"a.gif", "b.webp"}, out); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("output not written: %v", err)
+	}
+
+	calls := fr.ffmpegCalls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d ffmpeg calls; want 2 segments + concat", len(calls))
+	}
+	seg := strings.Join(calls[0], " ")
+	if !strings.Contains(seg, "fps=12,scale=102:50") {
+		t.Errorf("segment filter does not use options and even canvas: %s", seg)
+	}
+
+	for _, want := range []EventKind{EventProbed, EventSegmentStarted, EventSegmentDone, EventConcatStarted, EventDone} {
+		if !slices.Contains(kinds, want) {
+			t.Errorf("missing %s event in %v", want, kinds)
+		}
+	}
+	if kinds[len(kinds)-1] != EventDone {
+		t.Errorf("last event = %s; want %s", kinds[len(kinds)-1], EventDone)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	tmp := t.TempDir()
+	fr := &fakeRunner{fail: "concat"}
+	var got []Event
+	b := New(Options{TmpDir: tmp, Concurrency: 1})
+	b.Runner = fr
+	b.Events = EventHandlerFunc(func(e Event) { got = append(got, e) })
+
+	err := b.Build(context.Background(), []string{"a.gif"}, filepath.Join(tmp, "out.mp4"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	last := got[len(got)-1]
+	if last.Kind != EventError || last.Err != err {
+		t.Errorf("last event = %+v; want EventError carrying the returned error", last)
+	}
+}
+
+func TestBuildHookAndNotify(t *testing.T) {
+	tmp := t.TempDir()
+	var body map[string]any
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(notify.HeaderSignature)
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	var got []Result
+	out := filepath.Join(tmp, "out.mp4")
+	b := New(Options{TmpDir: filepath.Join(tmp, "work"), Notify: Notify{URL: srv.URL, Secret: "s3cret"}})
+	b.Runner = &fakeRunner{}
+	b.Hook = HookFunc(func(_ context.Context, r Result) { got = append(got, r) })
+	if err := b.Build(context.Background(), []string{"a.gif", "b.gif"}, out); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("hook called %d times; want 1", len(got))
+	}
+	r := got[0]
+	if r.Err != nil || r.Output != out || r.Duration != 3 || r.Size != 3 || len(r.Inputs) != 2 || r.Inputs[1].Start != 1.5 {
+		t.Errorf("hook result = %+v", r)
+	}
+	if body["output"] != out || body["success"] != true || body["ffmpeg_version"] != "ffmpeg version 7.1-test" {
+		t.Errorf("notification body = %v", body)
+	}
+	if !strings.HasPrefix(sig, "sha256=") {
+		t.Errorf("signature header = %q", sig)
+	}
+
+	// A build that fails before running still reaches the hook.
+	got = nil
+	if err := b.Build(context.Background(), nil, out); err == nil || len(got) != 1 || got[0].Err != err {
+		t.Errorf("err = %v, hook results = %+v; want the error passed to the hook", err, got)
+	}
+
+	// Canceling the build doesn't cancel the notification of it.
+	body = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := filepath.Join(tmp, "canceled.mp4")
+	_ = b.Build(ctx, []string{"a.gif"}, canceled)
+	if body["output"] != canceled {
+		t.Errorf("notification of a canceled build = %v", body)
+	}
+}
+
+func TestBuildNotifyFailure(t *testing.T) {
+	tmp := t.TempDir()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	b := New(Options{TmpDir: tmp, Notify: Notify{URL: srv.URL, Retries: -1}})
+	b.Runner = &fakeRunner{}
+	err := b.Build(context.Background(), []string{"a.gif"}, filepath.Join(tmp, "out.mp4"))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("err = %v; want the failed delivery", err)
+	}
+}
+
+func TestBuilderConfig(t *testing.T) {
+	b := New(Options{Profile: "twitter", FPS: 25})
+	cfg, err := b.config()
+	if err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	if cfg.FPS != 25 {
+		t.Errorf("FPS = %d; want option to override profile", cfg.FPS)
+	}
+	if cfg.Width != 1280 || cfg.Height != 720 {
+		t.Errorf("canvas = %dx%d; want profile's 1280x720", cfg.Width, cfg.Height)
+	}
+	if cfg.CRF != 23 || cfg.Preset != "medium" {
+		t.Errorf("defaults not applied: crf=%d preset=%q", cfg.CRF, cfg.Preset)
+	}
+
+	if cfg, err := New(Options{Profile: "twitter", CRF: -1}).config(); err != nil {
+		t.Errorf("config with CRF -1 failed: %v", err)
+	} else if cfg.CRF != 0 {
+		t.Errorf("CRF -1 gave crf=%d; want lossless 0", cfg.CRF)
+	}
+
+	cfg, err = New(Options{
+		Text:      Text{Watermark: "©", WatermarkPosition: "bottom-left"},
+		Watermark: Logo{Path: "logo.png", Position: "top-left"},
+	}).config()
+	if err != nil {
+		t.Fatalf("config with text and image watermarks failed: %v", err)
+	}
+	if cfg.WatermarkPos != "bottom-left" || cfg.ImagePosition() != "top-left" {
+		t.Errorf("watermark positions: text %q, image %q", cfg.WatermarkPos, cfg.ImagePosition())
+	}
+
+	if _, err := New(Options{Codec: "mpeg4"}).config(); err == nil {
+		t.Error("expected error for unsupported codec")
+	}
+}
@@ -2,18 +2,555 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/doctor"
 	"github.com/crit/gif2vid/internal/ffmpeg"
 	"github.com/crit/gif2vid/internal/inputs"
+	"github.com/crit/gif2vid/internal/notify"
 	"github.com/crit/gif2vid/internal/pipeline"
+	"github.com/crit/gif2vid/internal/report"
+	"github.com/crit/gif2vid/internal/server"
+	"github.com/crit/gif2vid/internal/watch"
 )
 
 // Run is the main orchestration entry point.
 func Run(ctx context.Context, cfg *config.Config) error {
+	return run(ctx, cfg, pipeline.Run)
+}
+
+// Grid tiles the inputs on one canvas, playing at the same time.
+func Grid(ctx context.Context, cfg *config.Config) error {
+	return run(ctx, cfg, pipeline.RunGrid)
+}
+
+// Compare pairs the files of two directories by name and stacks each pair.
+// Files without a counterpart are listed on the log and in the report.
+func Compare(ctx context.Context, cfg *config.Config) error {
+	return run(ctx, cfg, func(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs pipeline.Observer) (*pipeline.Result, error) {
+		var err error
+		if cfg.CompareInputs, err = findInputs(cfg, cfg.CompareDir, cfg.Extensions()); err != nil {
+			return nil, err
+		}
+		res, err := pipeline.RunCompare(ctx, r, cfg, obs)
+		if res != nil {
+			w := logWriter(cfg)
+			for _, p := range res.Unmatched {
+				fmt.Fprintf(w, "[gif2vid] no match for %s\n", p)
+			}
+		}
+		return res, err
+	})
+}
+
+// PIP plays the files of cfg.InsetDir as an inset over those of cfg.InputDir.
+func PIP(ctx context.Context, cfg *config.Config) error {
+	return run(ctx, cfg, func(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs pipeline.Observer) (*pipeline.Result, error) {
+		var err error
+		if cfg.InsetInputs, err = findInputs(cfg, cfg.InsetDir, cfg.Extensions()); err != nil {
+			return nil, err
+		}
+		return pipeline.RunPIP(ctx, r, cfg, obs)
+	})
+}
+
+// Batch builds one output per immediate subdirectory of cfg.InputDir into the
+// cfg.Output directory, named from cfg.OutputTemplate. The jobs share one pool
+// of encoders and their probe and segment caches; a failed job doesn't stop
+// the others.
+func Batch(ctx context.Context, cfg *config.Config) error {
+	started := time.Now()
+	if inputs.IsArchive(cfg.InputDir) {
+		return errors.New("--batch reads a directory, not an archive")
+	}
+	if err := checkTools(cfg); err != nil {
+		return err
+	}
+	exts := cfg.Extensions()
+	if len(exts) == 0 {
+		return errors.New("--ext must list at least one extension")
+	}
+	names, err := subdirs(cfg.InputDir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no subdirectories in %s", cfg.InputDir)
+	}
+	if err := os.MkdirAll(cfg.Output, 0o755); err != nil {
+		return err
+	}
+	batchDir, err := pipeline.NewWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+	if !cfg.KeepTemp {
+		defer os.RemoveAll(batchDir)
+	}
+
+	r := ffmpeg.ExecRunner{}
+	var version string
+	if cfg.Report != "" || cfg.JSON || cfg.NotifyURL != "" {
+		version, _ = ffmpeg.Version(ctx, r, "ffmpeg")
+	}
+	sh := pipeline.NewShared(cfg.Concurrency, batchDir)
+	jobs := make([]batchJob, len(names))
+	// The shared pool bounds segment encodes; this bounds the joins and
+	// muxes that follow them.
+	running := make(chan struct{}, cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Go(func() {
+			job := &jobs[i]
+			job.started = time.Now()
+			job.cfg = batchConfig(cfg, name, batchDir)
+			running <- struct{}{}
+			defer func() { <-running }()
+			if job.cfg.Inputs, job.err = findInputs(job.cfg, job.cfg.InputDir, exts); job.err == nil {
+				job.res, job.err = sh.Run(ctx, r, job.cfg, logObserver(cfg, name))
+			}
+			if hook := notify.New(job.cfg); hook != nil {
+				rep := report.New(job.cfg, job.res, job.err, version, job.started)
+				ctx, cancel := notify.Detached(ctx)
+				defer cancel()
+				if err := hook.Send(ctx, rep); err != nil && job.err == nil {
+					job.err = err
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	w := logWriter(cfg)
+	failed := 0
+	for _, job := range jobs {
+		if job.err != nil {
+			failed++
+			fmt.Fprintf(w, "[gif2vid] %s: failed: %v\n", filepath.Base(job.cfg.InputDir), job.err)
+		} else {
+			fmt.Fprintf(w, "[gif2vid] %s: wrote %s\n", filepath.Base(job.cfg.InputDir), job.cfg.Output)
+		}
+	}
+	if cfg.Report != "" || cfg.JSON {
+		reps := make([]*report.Report, len(jobs))
+		for i, job := range jobs {
+			reps[i] = report.New(job.cfg, job.res, job.err, version, job.started)
+		}
+		rep := report.NewBatch(reps)
+		if cfg.Report != "" {
+			if err := rep.WriteFile(cfg.Report); err != nil {
+				return fmt.Errorf("writing report: %w", err)
+			}
+		}
+		if cfg.JSON {
+			if err := rep.Encode(os.Stdout); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Verbose {
+		fmt.Fprintf(w, "[gif2vid] batch finished in %s\n", time.Since(started).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d batches failed", failed, len(jobs))
+	}
+	return nil
+}
+
+// Watch builds cfg.Output, then rebuilds it whenever the files of
+// cfg.InputDir change, until ctx is done. Rebuilds encode only new or changed
+// inputs, and each new output replaces the last in one rename. A failed build
+// is logged and the next change tries again.
+func Watch(ctx context.Context, cfg *config.Config) error {
+	if inputs.IsArchive(cfg.InputDir) {
+		return errors.New("watch reads a directory, not an archive")
+	}
+	if err := checkTools(cfg); err != nil {
+		return err
+	}
+	exts := cfg.Extensions()
+	if len(exts) == 0 {
+		return errors.New("--ext must list at least one extension")
+	}
+	if _, err := os.Stat(cfg.Output); err == nil && !cfg.Overwrite {
+		return fmt.Errorf("output exists: %s (use --overwrite)", cfg.Output)
+	}
+	// Every rebuild replaces the output of the one before.
+	cfg.Overwrite = true
+	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
+		return err
+	}
+	watchDir, err := pipeline.NewWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+	if !cfg.KeepTemp {
+		defer os.RemoveAll(watchDir)
+	}
+	cfg.TmpDir = filepath.Join(watchDir, "build")
+
+	// Watch before the first build so changes made during it aren't missed.
+	changes, err := watch.Changes(ctx, cfg.InputDir, watch.Options{
+		Match:    watchMatch(cfg, exts),
+		Debounce: seconds(cfg.Debounce),
+		Interval: seconds(cfg.PollInterval),
+		Poll:     cfg.Poll,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(logWriter(cfg), "[gif2vid] watching %s\n", cfg.InputDir)
+	sh := pipeline.NewShared(cfg.Concurrency, watchDir)
+	rebuild(ctx, cfg, sh, exts)
+	for range changes {
+		rebuild(ctx, cfg, sh, exts)
+	}
+	return nil
+}
+
+// rebuild runs one build of Watch, logging the outcome.
+func rebuild(ctx context.Context, cfg *config.Config, sh *pipeline.Shared, exts []string) {
+	started := time.Now()
+	w := logWriter(cfg)
+	var err error
+	// The preview may be written into the watched directory; it must not
+	// become an input of the next rebuild.
+	if cfg.Inputs, err = findInputs(cfg, cfg.InputDir, exts); err != nil {
+		fmt.Fprintf(w, "[gif2vid] %v\n", err)
+		return
+	}
+	r := ffmpeg.ExecRunner{}
+	res, err := sh.Run(ctx, r, cfg, logObserver(cfg, ""))
+	if ctx.Err() != nil {
+		return
+	}
+	if rerr := reportRun(ctx, r, cfg, res, err, started); rerr != nil {
+		fmt.Fprintf(w, "[gif2vid] %v\n", rerr)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "[gif2vid] build failed: %v\n", err)
+		return
+	}
+	encoded := 0
+	for _, seg := range res.Segments {
+		if !seg.Cached {
+			encoded++
+		}
+	}
+	fmt.Fprintf(w, "[gif2vid] wrote %s (%d of %d segments encoded) in %s\n",
+		cfg.Output, encoded, len(res.Segments), time.Since(started).Round(time.Millisecond))
+}
+
+// watchMatch reports whether a file of the input directory affects the
+// build: an input, a caption sidecar or the manifest, but not the output.
+func watchMatch(cfg *config.Config, exts []string) func(string) bool {
+	return func(name string) bool {
+		p := filepath.Join(cfg.InputDir, name)
+		if pipeline.IsOutput(cfg, p) {
+			return false
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		return slices.Contains(exts, ext) || ext == ".txt" || (cfg.Manifest != "" && same(p, cfg.Manifest))
+	}
+}
+
+// same reports whether paths a and b name the same file.
+func same(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Serve runs the HTTP job API on addr until ctx is done. Jobs work under a
+// temp workspace of the server's own, which is removed on exit unless
+// --keep-temp.
+func Serve(ctx context.Context, cfg *config.Config, addr string, opts server.Options) error {
+	if err := checkTools(cfg); err != nil {
+		return err
+	}
+	dir, err := pipeline.NewWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+	opts.Dir = dir
+	opts.Runner = ffmpeg.ExecRunner{}
+	opts.MagickBin = cfg.MagickBin
+	opts.Encoders = cfg.Concurrency
+	if opts.Encoders <= 0 {
+		opts.Encoders = runtime.NumCPU()
+	}
+	s, err := server.New(opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.Close()
+		if !cfg.KeepTemp {
+			_ = os.RemoveAll(opts.Dir)
+		}
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler()}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	fmt.Fprintf(logWriter(cfg), "[gif2vid] serving on http://%s\n", ln.Addr())
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// batchJob is one subdirectory of a batch.
+type batchJob struct {
+	cfg     *config.Config
+	res     *pipeline.Result
+	err     error
+	started time.Time
+}
+
+// subdirs lists the names of the immediate, non-hidden subdirectories of dir.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// batchConfig is cfg for the subdirectory name of a batch: its output comes
+// from the template, its temp files go under batchDir, and a manifest is the
+// file of the same name inside the subdirectory, if there is one.
+func batchConfig(cfg *config.Config, name, batchDir string) *config.Config {
+	c := *cfg
+	c.InputDir = filepath.Join(cfg.InputDir, name)
+	c.Output = filepath.Join(cfg.Output, strings.ReplaceAll(cfg.OutputTemplate, "{dir}", name))
+	c.TmpDir = filepath.Join(batchDir, "jobs", name)
+	c.Report, c.JSON = "", false
+	if cfg.Manifest != "" {
+		c.Manifest = filepath.Join(c.InputDir, filepath.Base(cfg.Manifest))
+		if _, err := os.Stat(c.Manifest); err != nil {
+			c.Manifest = ""
+		}
+	}
+	return &c
+}
+
+// run resolves the inputs, runs mode and writes the report.
+func run(ctx context.Context, cfg *config.Config, mode func(context.Context, ffmpeg.Runner, *config.Config, pipeline.Observer) (*pipeline.Result, error)) error {
+	started := time.Now()
+	r := ffmpeg.ExecRunner{}
+	var res *pipeline.Result
+	cleanup, err := setup(cfg)
+	defer cleanup()
+	if err == nil {
+		// Ensure output parent exists (later we also check overwrite)
+		err = os.MkdirAll(filepath.Dir(cfg.Output), 0o755)
+	}
+	if err == nil {
+		res, err = mode(ctx, r, cfg, logObserver(cfg, ""))
+	}
+	// A failed setup is reported too, with none of the inputs built.
+	if rerr := reportRun(ctx, r, cfg, res, err, started); rerr != nil {
+		if err != nil {
+			fmt.Fprintf(logWriter(cfg), "[gif2vid] %v\n", rerr)
+		} else {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// reportRun writes the run report to --report and/or stdout for --json, and
+// POSTs it to --notify-url.
+func reportRun(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, res *pipeline.Result, runErr error, started time.Time) error {
+	if cfg.Report == "" && !cfg.JSON && cfg.NotifyURL == "" {
+		return nil
+	}
+	ctx, cancel := notify.Detached(ctx)
+	defer cancel()
+	version, _ := ffmpeg.Version(ctx, r, "ffmpeg")
+	rep := report.New(cfg, res, runErr, version, started)
+	if cfg.Report != "" {
+		if err := rep.WriteFile(cfg.Report); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+	}
+	if cfg.JSON {
+		if err := rep.Encode(os.Stdout); err != nil {
+			return err
+		}
+	}
+	if hook := notify.New(cfg); hook != nil {
+		return hook.Send(ctx, rep)
+	}
+	return nil
+}
+
+// logObserver prints pipeline progress the way the CLI always has: the kept
+// temp dir always, everything else only with --verbose. Lines of a batch job
+// name the job.
+func logObserver(cfg *config.Config, job string) pipeline.Observer {
+	var mu sync.Mutex
+	prefix := "[gif2vid] "
+	if job != "" {
+		prefix += job + ": "
+	}
+	return pipeline.ObserverFunc(func(e pipeline.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		w := logWriter(cfg)
+		switch e.Kind {
+		case pipeline.EventTempKept:
+			fmt.Fprintf(w, prefix+"temp kept at: %s\n", e.Path)
+		case pipeline.EventSegmentDone:
+			if cfg.Verbose {
+				fmt.Fprintf(w, prefix+"segment %d/%d done: %s\n", e.Index+1, e.Total, filepath.Base(e.Input))
+			}
+		case pipeline.EventFallback:
+			if cfg.Verbose {
+				fmt.Fprintf(w, prefix+"ffmpeg could not decode %s, using ImageMagick\n", filepath.Base(e.Input))
+			}
+		case pipeline.EventConcatStarted:
+			if cfg.Verbose {
+				fmt.Fprintf(w, prefix+"joining %d segments\n", e.Total)
+			}
+		}
+	})
+}
+
+// Probe prints the dimensions of every supported input in cfg.InputDir.
+func Probe(ctx context.Context, cfg *config.Config, w io.Writer) error {
+	cleanup, err := setup(cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	plan, err := pipeline.NewPlan(ctx, ffmpeg.ExecRunner{}, cfg)
+	if err != nil {
+		return err
+	}
+	for _, in := range plan.Inputs {
+		fmt.Fprintf(w, "%s\t%dx%d\n", in.Path, in.Width, in.Height)
+	}
+	return nil
+}
+
+// Plan prints the canvas and per-segment filter a build would use, without encoding.
+func Plan(ctx context.Context, cfg *config.Config, w io.Writer) error {
+	cleanup, err := setup(cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	plan, err := pipeline.NewPlan(ctx, ffmpeg.ExecRunner{}, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "canvas: %dx%d @ %d fps\n", plan.Width, plan.Height, cfg.FPS)
+	fmt.Fprintf(w, "filter: %s\n", pipeline.BuildFilter(cfg, plan.Width, plan.Height))
+	for i, in := range plan.Inputs {
+		fmt.Fprintf(w, "seg_%04d.mp4\t%dx%d\t%s\n", i, in.Width, in.Height, in.Path)
+	}
+	if wm := plan.Watermark; wm != nil {
+		fmt.Fprintf(w, "watermark: %dx%d\t%s\n", wm.Width, wm.Height, wm.Path)
+	}
+	if cfg.Output != "" {
+		fmt.Fprintf(w, "output: %s\n", cfg.Output)
+	}
+	return nil
+}
+
+// logWriter is where progress logs go; stdout is reserved for the report with --json.
+func logWriter(cfg *config.Config) io.Writer {
+	if cfg.JSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// setup checks for the required binaries and resolves the input files. An
+// input archive is extracted into a workspace of its own; cleanup removes it
+// unless --keep-temp.
+func setup(cfg *config.Config) (cleanup func(), err error) {
+	cleanup = func() {}
+	if err := checkTools(cfg); err != nil {
+		return cleanup, err
+	}
+
+	// Validate inputs
+	exts := cfg.Extensions()
+	if len(exts) == 0 {
+		return cleanup, errors.New("--ext must list at least one extension")
+	}
+	if !inputs.IsArchive(cfg.InputDir) {
+		cfg.Inputs, err = findInputs(cfg, cfg.InputDir, exts)
+		return cleanup, err
+	}
+
+	dir, err := pipeline.NewWorkspace(cfg)
+	if err != nil {
+		return cleanup, err
+	}
+	if !cfg.KeepTemp {
+		cleanup = func() { _ = os.RemoveAll(dir) }
+	}
+	cfg.Inputs, err = inputs.ExtractArchive(cfg.InputDir, dir, exts, int64(cfg.MaxEntry))
+	if err != nil {
+		cleanup()
+		return func() {}, err
+	}
+	if cfg.Verbose {
+		fmt.Fprintf(logWriter(cfg), "[gif2vid] extracted %d inputs from %s\n", len(cfg.Inputs), cfg.InputDir)
+	}
+	return cleanup, nil
+}
+
+// findInputs lists the files in dir with one of exts, leaving out cfg's own
+// output and its split parts, which -o may put there.
+func findInputs(cfg *config.Config, dir string, exts []string) ([]string, error) {
+	files, err := inputs.FindFiles(dir, exts)
+	if err != nil {
+		return nil, err
+	}
+	files = slices.DeleteFunc(files, func(p string) bool { return pipeline.IsOutput(cfg, p) })
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no inputs in %s besides the output %s", dir, cfg.Output)
+	}
+	return files, nil
+}
+
+// checkTools checks for ffmpeg and ffprobe and looks for ImageMagick.
+func checkTools(cfg *config.Config) error {
 	// Check environment binaries early
 	if _, err := ffmpeg.LookPath("ffmpeg"); err != nil {
 		return err
@@ -23,31 +560,115 @@ func Run(ctx context.Context, cfg *config.Config) error {
 	}
 
 	// Optional ImageMagick fallback
-	if _, err := ffmpeg.LookPath("magick"); err == nil {
-		cfg.MagickBin = "magick"
-	} else if _, err := ffmpeg.LookPath("convert"); err == nil {
-		cfg.MagickBin = "convert"
-	}
+	cfg.MagickBin = ffmpeg.FindMagick()
 
 	if cfg.Verbose {
-		fmt.Println("[gif2vid] ffmpeg/ffprobe found in PATH")
+		w := logWriter(cfg)
+		fmt.Fprintln(w, "[gif2vid] ffmpeg/ffprobe found in PATH")
 		if cfg.MagickBin != "" {
-			fmt.Printf("[gif2vid] ImageMagick found: %s\n", cfg.MagickBin)
+			fmt.Fprintf(w, "[gif2vid] ImageMagick found: %s\n", cfg.MagickBin)
 		}
 	}
+	return nil
+}
 
-	// Validate inputs
-	absInputs, err := inputs.GetFilesFromDir(cfg.InputDir)
+// Doctor checks the external tools and workspace a build depends on, printing
+// a report to w. It returns an error when any check fails (or warns, if strict).
+func Doctor(ctx context.Context, cfg *config.Config, w io.Writer, strict bool, minFree uint64) error {
+	base := pipeline.WorkspaceBase(cfg)
+	encoders := []string{cfg.Codec}
+	if cfg.HasAudio() {
+		encoders = append(encoders, config.AudioCodecs[cfg.AudioCodec])
+	}
+	checks := doctor.Run(ctx, ffmpeg.ExecRunner{}, doctor.Options{
+		Encoders: encoders,
+		Filters:  buildFilters(cfg),
+		CommandFilters: map[string][]string{
+			"grid":    {"xstack"},
+			"compare": {"tpad", "hstack", "vstack"},
+			"pip":     {"overlay"},
+		},
+		TmpDir:  base,
+		MinFree: minFree,
+	})
+	doctor.Print(w, checks)
+	if doctor.Failed(checks, strict) {
+		return fmt.Errorf("environment check failed")
+	}
+	return nil
+}
+
+// buildFilters lists the ffmpeg filters a build with cfg's settings uses. A
+// manifest may turn on any per-clip effect, so it adds all of theirs.
+func buildFilters(cfg *config.Config) []string {
+	filters := slices.Clip(doctor.DefaultFilters)
+	add := func(ok bool, names ...string) {
+		if ok {
+			filters = append(filters, names...)
+		}
+	}
+	manifest := cfg.Manifest != ""
+	trim := manifest || !cfg.TrimStart.IsZero() || !cfg.TrimEnd.IsZero()
+	reverse := manifest || cfg.Reverse
+	speed := manifest || (cfg.Speed != 0 && cfg.Speed != 1)
+	add(cfg.HasText(), "drawtext")
+	add(cfg.Watermark != "", "overlay", "colorchannelmixer")
+	add(cfg.Intro != "" || cfg.Outro != "", "color")
+	add(manifest || (cfg.StillMotion != "" && cfg.StillMotion != "none"), "zoompan")
+	add(trim, "trim")
+	add(trim || speed, "setpts")
+	add(reverse || cfg.Boomerang, "reverse")
+	add(manifest || cfg.Boomerang, "split", "concat")
+	if cfg.AudioPerClip {
+		add(true, "aresample", "aformat", "apad", "anullsrc")
+		add(trim, "atrim", "asetpts")
+		add(reverse, "areverse")
+		add(speed, "atempo")
+	}
+	add(cfg.SilentAudio, "anullsrc")
+	if cfg.Audio != "" {
+		add(true, "aresample", "aformat", "apad", "atrim")
+		add(cfg.AudioVolume != 1, "volume")
+		add(cfg.AudioFadeIn > 0 || cfg.AudioFadeOut > 0, "afade")
+		add(cfg.AudioPerClip, "amix")
+	}
+	slices.Sort(filters[len(doctor.DefaultFilters):])
+	return slices.Compact(filters)
+}
+
+// CachePath prints the directory that builds create their temp workspaces in.
+func CachePath(cfg *config.Config, w io.Writer) error {
+	dir, err := filepath.Abs(pipeline.WorkspaceBase(cfg))
 	if err != nil {
 		return err
 	}
-	cfg.Inputs = absInputs
+	fmt.Fprintln(w, dir)
+	return nil
+}
 
-	// Ensure output parent exists (later we also check overwrite)
-	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
+// CacheClean removes the workspaces gif2vid created in the temp directory,
+// including any kept with --keep-temp. Nothing else there is touched, so
+// --tmp-dir may point at a directory shared with other files.
+func CacheClean(cfg *config.Config, w io.Writer) error {
+	base := pipeline.WorkspaceBase(cfg)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
-
-	r := ffmpeg.ExecRunner{}
-	return pipeline.Run(ctx, r, cfg)
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "gif2vid-") {
+			continue
+		}
+		dir := filepath.Join(base, e.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if cfg.Verbose {
+			fmt.Fprintf(w, "[gif2vid] removed %s\n", dir)
+		}
+	}
+	return nil
 }
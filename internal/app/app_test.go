@@ -0,0 +1,63 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/crit/gif2vid/internal/config"
+)
+
+func TestFindInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.gif", "b.png", "reel.mp4", "reel_001.mp4", "reel_1080.mp4", "reel_x.mp4", "clip.mp4"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.New()
+	cfg.Output = filepath.Join(dir, "reel.mp4")
+
+	// The output and its split parts in the input directory aren't inputs.
+	got, err := findInputs(cfg, dir, []string{".gif", ".png", ".mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range got {
+		names = append(names, filepath.Base(p))
+	}
+	want := []string{"a.gif", "b.png", "clip.mp4", "reel_1080.mp4", "reel_x.mp4"}
+	if !slices.Equal(names, want) {
+		t.Errorf("inputs = %v; want %v", names, want)
+	}
+
+	if _, err := findInputs(cfg, dir, []string{".gif"}); err != nil {
+		t.Errorf("gif inputs: %v", err)
+	}
+	only := t.TempDir()
+	cfg.Output = filepath.Join(only, "reel.mp4")
+	if err := os.WriteFile(cfg.Output, []byte("mp4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := findInputs(cfg, only, []string{".mp4"}); err == nil {
+		t.Error("expected an error when the output is the only input")
+	}
+}
+
+func TestWatchMatch(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.New()
+	cfg.InputDir = dir
+	cfg.Output = filepath.Join(dir, "preview.mp4")
+	match := watchMatch(cfg, []string{".gif", ".mp4"})
+	for name, want := range map[string]bool{
+		"a.gif": true, "a.txt": true, "clip.mp4": true,
+		"preview.mp4": false, "preview_002.mp4": false, "notes.md": false,
+	} {
+		if got := match(name); got != want {
+			t.Errorf("match(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
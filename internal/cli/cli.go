@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Command is a gif2vid subcommand.
+type Command struct {
+	Name    string
+	Args    string // positional arguments shown in usage
+	Summary string
+	// Flags defines the command's flags and returns a function that runs it with the remaining args.
+	Flags func(fs *flag.FlagSet, env *Env) func(ctx context.Context, args []string) error
+}
+
+// Env is what commands write to.
+type Env struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// usageError marks errors caused by bad invocation; they print usage and exit with 2.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// UsageError wraps err so Main reports it alongside the command usage.
+func UsageError(err error) error { return usageError{err} }
+
+// defaultCommand runs when the first argument is not a command name, so that
+// `gif2vid -o out.mp4 dir` keeps working.
+const defaultCommand = "build"
+
+// Main runs the CLI with args (without the program name) and returns the exit code.
+func Main(ctx context.Context, args []string, env *Env) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		if len(args) > 1 {
+			if cmd := lookup(args[1]); cmd != nil {
+				fs, _ := newFlagSet(cmd, env)
+				fs.SetOutput(env.Stdout)
+				fs.Usage()
+				return 0
+			}
+			fmt.Fprintf(env.Stderr, "gif2vid: unknown command %q\n", args[1])
+			return 2
+		}
+		printUsage(env.Stdout)
+		return 0
+	}
+	if len(args) == 0 {
+		printUsage(env.Stderr)
+		return 2
+	}
+
+	cmd, rest := resolve(args)
+	fs, run := newFlagSet(cmd, env)
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := run(ctx, fs.Args()); err != nil {
+		var ue usageError
+		if errors.As(err, &ue) {
+			fmt.Fprintf(env.Stderr, "error: %v\n", err)
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(env.Stderr, "gif2vid: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// resolve picks the command for args. Global flags may precede the command
+// name; anything else falls back to the default build command.
+func resolve(args []string) (*Command, []string) {
+	if cmd := lookup(args[0]); cmd != nil {
+		return cmd, args[1:]
+	}
+	gfs := flag.NewFlagSet("gif2vid", flag.ContinueOnError)
+	gfs.SetOutput(io.Discard)
+	addGlobalFlags(gfs)
+	if err := gfs.Parse(args); err == nil && gfs.NArg() > 0 {
+		if cmd := lookup(gfs.Arg(0)); cmd != nil {
+			var globals []string
+			gfs.Visit(func(f *flag.Flag) {
+				globals = append(globals, "-"+f.Name+"="+f.Value.String())
+			})
+			return cmd, append(globals, gfs.Args()[1:]...)
+		}
+	}
+	return lookup(defaultCommand), args
+}
+
+func lookup(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func newFlagSet(cmd *Command, env *Env) (*flag.FlagSet, func(ctx context.Context, args []string) error) {
+	fs := flag.NewFlagSet("gif2vid "+cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	run := cmd.Flags(fs, env)
+	fs.Usage = func() {
+		w := fs.Output()
+		usage := "gif2vid " + cmd.Name + " [flags]"
+		if cmd.Args != "" {
+			usage += " " + cmd.Args
+		}
+		fmt.Fprintf(w, "Usage: %s\n\n%s\n", usage, cmd.Summary)
+		if hasFlags(fs) {
+			fmt.Fprintln(w, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs, run
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gif2vid [global flags] <command> [flags] [args]")
+	fmt.Fprintln(w, "       gif2vid [flags] <input_directory>   (same as build)")
+	fmt.Fprintln(w, "\nCommands:")
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.Name))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, c.Name, c.Summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	gfs := flag.NewFlagSet("gif2vid", flag.ContinueOnError)
+	gfs.SetOutput(w)
+	addGlobalFlags(gfs)
+	gfs.PrintDefaults()
+	fmt.Fprintln(w, "\nRun 'gif2vid help <command>' for command flags.")
+}
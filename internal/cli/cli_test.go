@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantRest []string
+	}{
+		{"legacy invocation", []string{"-o", "out.mp4", "dir"}, "build", []string{"-o", "out.mp4", "dir"}},
+		{"legacy dir only", []string{"dir"}, "build", []string{"dir"}},
+		{"explicit build", []string{"build", "-o", "out.mp4", "dir"}, "build", []string{"-o", "out.mp4", "dir"}},
+		{"subcommand", []string{"probe", "dir"}, "probe", []string{"dir"}},
+		{"global flags before subcommand", []string{"--verbose", "-j", "2", "plan", "dir"}, "plan", []string{"-j=2", "-verbose=true", "dir"}},
+		{"global flags then legacy dir", []string{"--verbose", "dir"}, "build", []string{"--verbose", "dir"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, rest := resolve(tt.args)
+			if cmd.Name != tt.wantCmd {
+				t.Errorf("command = %q; want %q", cmd.Name, tt.wantCmd)
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+				t.Errorf("rest = %q; want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Main(context.Background(), args, &Env{Stdout: &stdout, Stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestMainUsage(t *testing.T) {
+	t.Run("no args", func(t *testing.T) {
+		code, _, stderr := run()
+		if code != 2 {
+			t.Errorf("exit = %d; want 2", code)
+		}
+		if !strings.Contains(stderr, "Commands:") {
+			t.Errorf("expected command list, got %q", stderr)
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		code, stdout, _ := run("help")
+		if code != 0 {
+			t.Errorf("exit = %d; want 0", code)
+		}
+		for _, c := range commands {
+			if !strings.Contains(stdout, c.Name) {
+				t.Errorf("help missing command %q", c.Name)
+			}
+		}
+	})
+
+	t.Run("help command", func(t *testing.T) {
+		code, stdout, _ := run("help", "build")
+		if code != 0 {
+			t.Errorf("exit = %d; want 0", code)
+		}
+		if !strings.Contains(stdout, "gif2vid build [flags] <input_directory>") || !strings.Contains(stdout, "-output") {
+			t.Errorf("unexpected build help: %q", stdout)
+		}
+	})
+
+	t.Run("help unknown command", func(t *testing.T) {
+		if code, _, _ := run("help", "nope"); code != 2 {
+			t.Errorf("exit = %d; want 2", code)
+		}
+	})
+
+	t.Run("build missing output", func(t *testing.T) {
+		code, _, stderr := run("dir")
+		if code != 2 {
+			t.Errorf("exit = %d; want 2", code)
+		}
+		if !strings.Contains(stderr, "-o/--output is required") {
+			t.Errorf("unexpected stderr: %q", stderr)
+		}
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		if code, _, _ := run("probe", "--nope", "dir"); code != 2 {
+			t.Errorf("exit = %d; want 2", code)
+		}
+	})
+}
+
+func TestVersion(t *testing.T) {
+	old := Version
+	defer func() { Version = old }()
+	Version = "v1.2.3"
+
+	code, stdout, _ := run("--verbose", "version")
+	if code != 0 {
+		t.Fatalf("exit = %d; want 0", code)
+	}
+	if stdout != "gif2vid v1.2.3\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+}
@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/crit/gif2vid/internal/app"
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/server"
+)
+
+// Version is the release version, set at build time with
+// -ldflags "-X github.com/crit/gif2vid/internal/cli.Version=v1.2.3".
+var Version = ""
+
+var commands = []*Command{
+	{
+		Name:    "build",
+		Args:    "<input_directory>",
+		Summary: "Combine the GIF/WebP files in a directory into one MP4 (default command).",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddBatchFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.Finalize(args); err != nil {
+					return UsageError(err)
+				}
+				if cfg.Batch {
+					return app.Batch(ctx, cfg)
+				}
+				return app.Run(ctx, cfg)
+			}
+		},
+	},
+	{
+		Name:    "watch",
+		Args:    "<input_directory>",
+		Summary: "Build like build, then rebuild whenever the input directory changes.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddWatchFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.Finalize(args); err != nil {
+					return UsageError(err)
+				}
+				ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				return app.Watch(ctx, cfg)
+			}
+		},
+	},
+	{
+		Name:    "grid",
+		Args:    "<input_directory>",
+		Summary: "Tile the files in a directory on one canvas, playing at the same time.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddGridFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.Finalize(args); err != nil {
+					return UsageError(err)
+				}
+				return app.Grid(ctx, cfg)
+			}
+		},
+	},
+	{
+		Name:    "compare",
+		Args:    "<dir_a> <dir_b>",
+		Summary: "Play files with the same name in two directories side by side, one pair after another.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddCompareFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.FinalizeCompare(args); err != nil {
+					return UsageError(err)
+				}
+				return app.Compare(ctx, cfg)
+			}
+		},
+	},
+	{
+		Name:    "pip",
+		Args:    "<main_directory> <inset_directory>",
+		Summary: "Build one directory's files as usual, with another's playing as an inset in a corner.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddPIPFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.FinalizePIP(args); err != nil {
+					return UsageError(err)
+				}
+				return app.PIP(ctx, cfg)
+			}
+		},
+	},
+	{
+		Name:    "serve",
+		Summary: "Run an HTTP API that queues builds of uploaded or server-local inputs.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddGlobalFlags(fs)
+			addr := fs.String("addr", "localhost:8080", "Address to listen on")
+			var opts server.Options
+			fs.IntVar(&opts.Workers, "workers", 1, "Number of jobs built at once (segment encodes across all jobs are limited by --concurrency)")
+			fs.IntVar(&opts.Queue, "queue", 100, "Number of jobs that may wait to run before submissions are refused")
+			fs.Func("allow-dir", "Server-local directory jobs may read inputs from (repeatable)", func(dir string) error {
+				opts.AllowDirs = append(opts.AllowDirs, dir)
+				return nil
+			})
+			fs.Func("allow-hook", "Origin, such as https://hooks.example.com, that a job's notify-url may be on (repeatable)", func(origin string) error {
+				opts.AllowHooks = append(opts.AllowHooks, origin)
+				return nil
+			})
+			maxUpload := config.ByteSize(1 << 30)
+			fs.Var(&maxUpload, "max-upload", "Largest accepted submission, such as 500M")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return UsageError(errors.New("serve takes no arguments"))
+				}
+				if err := cfg.Resolve(""); err != nil {
+					return err
+				}
+				opts.MaxUpload = int64(maxUpload)
+				ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				return app.Serve(ctx, cfg, *addr, opts)
+			}
+		},
+	},
+	{
+		Name:    "probe",
+		Args:    "<input_directory>",
+		Summary: "Print the dimensions of every supported file in a directory.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddInputFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.FinalizeDir(args); err != nil {
+					return UsageError(err)
+				}
+				return app.Probe(ctx, cfg, env.Stdout)
+			}
+		},
+	},
+	{
+		Name:    "plan",
+		Args:    "<input_directory>",
+		Summary: "Show the canvas, filter and segments a build would use, without encoding.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if err := cfg.FinalizeDir(args); err != nil {
+					return UsageError(err)
+				}
+				return app.Plan(ctx, cfg, env.Stdout)
+			}
+		},
+	},
+	{
+		Name:    "doctor",
+		Summary: "Check ffmpeg/ffprobe/ImageMagick versions, encoders, filters and temp space; exits non-zero on failure.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddFlags(fs)
+			strict := fs.Bool("strict", false, "Treat warnings (e.g. missing ImageMagick) as failures")
+			minFreeMB := fs.Uint64("min-free-mb", 1024, "Minimum free space required in the temp directory, in MiB")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return UsageError(errors.New("doctor takes no arguments"))
+				}
+				if err := cfg.Resolve(""); err != nil {
+					return err
+				}
+				if err := cfg.ApplyProfile(); err != nil {
+					return err
+				}
+				return app.Doctor(ctx, cfg, env.Stdout, *strict, *minFreeMB<<20)
+			}
+		},
+	},
+	{
+		Name:    "cache",
+		Args:    "path|clean",
+		Summary: "Print or remove the temporary workspace.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddGlobalFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if len(args) == 0 {
+					return UsageError(errors.New("cache requires one of: path, clean"))
+				}
+				if err := fs.Parse(args[1:]); err != nil {
+					return UsageError(err)
+				}
+				if fs.NArg() > 0 {
+					return UsageError(fmt.Errorf("unexpected arguments: %v", fs.Args()))
+				}
+				if err := cfg.Resolve(""); err != nil {
+					return err
+				}
+				switch args[0] {
+				case "path":
+					return app.CachePath(cfg, env.Stdout)
+				case "clean":
+					return app.CacheClean(cfg, env.Stdout)
+				}
+				return UsageError(fmt.Errorf("unknown cache action %q", args[0]))
+			}
+		},
+	},
+	{
+		Name:    "config",
+		Args:    "show|profiles [input_directory]",
+		Summary: "Print the effective configuration and where each value came from, or list the available profiles.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			cfg := config.AddFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if len(args) == 0 || (args[0] != "show" && args[0] != "profiles") {
+					return UsageError(errors.New("usage: config show|profiles [input_directory]"))
+				}
+				if err := fs.Parse(args[1:]); err != nil {
+					return UsageError(err)
+				}
+				if fs.NArg() > 1 {
+					return UsageError(errors.New("only one input directory is supported"))
+				}
+				dir := fs.Arg(0)
+				if err := cfg.Resolve(dir); err != nil {
+					return err
+				}
+				if args[0] == "profiles" {
+					cfg.ShowProfiles(env.Stdout)
+					return nil
+				}
+				if err := cfg.ApplyProfile(); err != nil {
+					return err
+				}
+				cfg.Show(env.Stdout)
+				return nil
+			}
+		},
+	},
+	{
+		Name:    "version",
+		Summary: "Print the gif2vid version.",
+		Flags: func(fs *flag.FlagSet, env *Env) func(context.Context, []string) error {
+			addGlobalFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				fmt.Fprintf(env.Stdout, "gif2vid %s\n", version())
+				return nil
+			}
+		},
+	},
+}
+
+// addGlobalFlags registers the flags every command accepts.
+func addGlobalFlags(fs *flag.FlagSet) {
+	config.AddGlobalFlags(fs)
+}
+
+func version() string {
+	if Version != "" {
+		return Version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return "dev"
+}
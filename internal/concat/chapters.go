@@ -0,0 +1,57 @@
+package concat
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Chapter is a titled span of the output, in seconds.
+type Chapter struct {
+	Title string
+	Start float64
+	End   float64
+}
+
+// EscapeMetadata escapes a value for an ffmetadata file, where '=', ';',
+// '#', '\' and newlines are special.
+func EscapeMetadata(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '=', ';', '#', '\\', '\n':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// WriteChaptersFile writes an ffmetadata file with one [CHAPTER] per entry,
+// for use as an extra ffmpeg input with -map_chapters.
+func WriteChaptersFile(path string, chapters []Chapter) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, ";FFMETADATA1")
+	for _, c := range chapters {
+		fmt.Fprintln(w, "[CHAPTER]")
+		fmt.Fprintln(w, "TIMEBASE=1/1000")
+		fmt.Fprintf(w, "START=%d\n", millis(c.Start))
+		fmt.Fprintf(w, "END=%d\n", millis(c.End))
+		fmt.Fprintf(w, "title=%s\n", EscapeMetadata(c.Title))
+	}
+	return w.Flush()
+}
+
+func millis(sec float64) int64 {
+	return int64(sec*1000 + 0.5)
+}
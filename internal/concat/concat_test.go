@@ -1,6 +1,7 @@
 package concat
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -32,3 +33,33 @@ func TestEscapePathForConcat_Abs(t *testing.T) {
 		t.Errorf("EscapePathForConcat(abs %q) = %q; want %q", absIn, got, want)
 	}
 }
+
+func TestWriteChaptersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapters.txt")
+	err := WriteChaptersFile(path, []Chapter{
+		{Title: "a.gif", Start: 0, End: 1.5},
+		{Title: "Q&A; part #2 = fun", Start: 1.5, End: 3.25},
+	})
+	if err != nil {
+		t.Fatalf("WriteChaptersFile failed: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `;FFMETADATA1
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=1500
+title=a.gif
+[CHAPTER]
+TIMEBASE=1/1000
+START=1500
+END=3250
+title=Q&A\; part \#2 \= fun
+`
+	if string(got) != want {
+		t.Errorf("chapters file =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -3,9 +3,40 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"net/url"
 	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/inputs"
+	"github.com/crit/gif2vid/internal/overlay"
+	"github.com/crit/gif2vid/internal/subtitles"
 )
 
+// Codecs are the video encoders the MP4 output supports.
+var Codecs = map[string]bool{
+	"libx264": true,
+	"libx265": true,
+}
+
+// StillMotions are the pan/zoom effects for still images.
+var StillMotions = []string{"none", "zoom-in", "zoom-out", "pan-left", "pan-right"}
+
+// Stacks are the ways compare places the two clips of a pair.
+var Stacks = []string{"horizontal", "vertical"}
+
+// InsetTimings are what pip does when the inset sequence ends before the
+// main one: loop it, or stop showing it.
+var InsetTimings = []string{"stop", "loop"}
+
+// AudioCodecs maps --audio-codec values to the ffmpeg encoders the MP4
+// output supports.
+var AudioCodecs = map[string]string{
+	"aac":  "aac",
+	"opus": "libopus",
+}
+
 // Config holds all CLI/configuration options.
 type Config struct {
 	Output      string
@@ -13,36 +44,355 @@ type Config struct {
 	CRF         int
 	Preset      string
 	BG          string
+	Profile     string
+	Width       int     // canvas width; 0 means the widest input
+	Height      int     // canvas height; 0 means the tallest input
+	Codec       string  // ffmpeg video encoder
+	Bitrate     string  // target video bitrate (e.g. "5M"); replaces CRF when set
+	MaxDuration float64 // seconds; 0 means unlimited
+	Manifest    string  // JSON file with per-clip settings (captions, ...)
+	StillDur    float64 // seconds each still image is shown
+	StillMotion string  // one of StillMotions
+	Speed       float64 // playback speed multiplier
+	Reverse     bool    // play backwards
+	Boomerang   bool    // play forward then reversed
+	Chapters    bool    // embed one MP4 chapter per input
+	Subtitles   string  // path of a .vtt/.srt caption sidecar
+	EmbedSubs   bool    // embed the captions as a mov_text track
+
+	TrimStart inputs.Mark // skip the start of every input
+	TrimEnd   inputs.Mark // cut every input here; zero is the end
+
+	// Splitting the output into numbered parts at clip boundaries; zero
+	// values are no limit.
+	SplitMaxDuration float64
+	SplitMaxSize     ByteSize // estimated from the encoded clips, before audio is added
+	SplitEvery       int      // inputs per part
+	SplitAllowCut    bool     // cut a clip that exceeds a limit on its own
+
+	Captions      bool   // burn each clip's caption into its segment
+	WatermarkText string // text burned into every frame
+	Timecode      bool   // burn the running output time into every frame
+	FontFile      string // TTF for text overlays; found automatically if empty
+	FontSize      int
+	FontColor     string
+	TextBox       bool // draw a translucent box behind overlay text
+	CaptionPos    string
+	WatermarkPos  string
+	// WatermarkImagePos places the watermark image; empty follows
+	// WatermarkPos (see ImagePosition).
+	WatermarkImagePos string
+	TimecodePos       string
+
+	Watermark        string  // image drawn over the final encode
+	WatermarkMargin  int     // pixels from the frame edge
+	WatermarkScale   float64 // width as a fraction of the canvas; 0 keeps the image size
+	WatermarkOpacity float64
+
+	Intro        string // title card text
+	IntroImage   string // title card background image
+	Outro        string // end card text
+	OutroImage   string // end card background image
+	CardDuration float64
+	CardColor    string // card background when there is no image
+	CardFontSize int
+
+	Audio        string // background audio file
+	AudioLoop    bool   // loop the background audio when it is shorter than the video
+	AudioFadeIn  float64
+	AudioFadeOut float64
+	AudioVolume  float64
+	AudioCodec   string // key of AudioCodecs
+	AudioBitrate string
+	AudioPerClip bool // keep each input's own sound
+	SilentAudio  bool // add a silent stereo track
+
+	// Grid layout (the grid command).
+	Cols         int     // columns; 0 picks a square-ish grid
+	Gutter       int     // pixels between cells, filled with BG
+	GridDuration float64 // seconds; 0 plays until the longest clip ends
+
+	// Comparison (the compare command): inputs are paired by filename with
+	// CompareInputs, found in CompareDir.
+	CompareDir    string
+	CompareInputs []string
+	Stack         string // one of Stacks
+	Labels        bool   // label each side
+	LabelA        string // defaults to the InputDir name
+	LabelB        string // defaults to the CompareDir name
+
+	// Picture-in-picture (the pip command): InsetInputs, found in InsetDir,
+	// play as a sequence in a corner of the main one.
+	InsetDir         string
+	InsetInputs      []string
+	InsetPos         string  // one of overlay.Positions
+	InsetScale       float64 // inset width as a fraction of the canvas width
+	InsetMargin      int     // pixels from the frame edge
+	InsetBorder      int     // pixels; 0 for none
+	InsetBorderColor string
+	InsetTiming      string // one of InsetTimings
+
+	Debounce     float64 // watch: seconds of quiet before a rebuild
+	Poll         bool    // watch: scan the directory instead of using inotify
+	PollInterval float64 // watch: seconds between scans
+
+	Batch          bool   // one output per subdirectory; Output is a directory
+	OutputTemplate string // batch output file name; {dir} is the subdirectory
+
+	NotifyURL     string // webhook POSTed the run report when a build ends
+	NotifySecret  string // HMAC key signing notifications
+	NotifyRetries int
+
+	Report      string // path of the JSON run report
+	JSON        bool   // print the JSON run report to stdout
 	Overwrite   bool
 	KeepTemp    bool
 	TmpDir      string
 	Verbose     bool
 	Concurrency int
 	InputDir    string
+	Ext         string   // comma-separated input extensions, such as "gif,webp"
+	MaxEntry    ByteSize // largest file extracted from an input archive
 	Inputs      []string
 	MagickBin   string // "magick" or "convert" if found
+
+	// Sources maps each setting to where its value came from (see Resolve).
+	Sources map[string]string
+
+	flags        *flag.FlagSet
+	resolved     bool
+	fileProfiles map[string]map[string]string
+}
+
+// New returns a Config with every setting at its default, for use outside
+// the CLI. It is never resolved from the environment or config files.
+func New() *Config {
+	c := AddAllFlags(flag.NewFlagSet("config", flag.ContinueOnError))
+	c.resolved = true
+	c.Sources = map[string]string{}
+	return c
 }
 
 // AddFlags defines CLI flags on the provided FlagSet and returns a pointer to Config.
 func AddFlags(fs *flag.FlagSet) *Config {
-	cfg := &Config{}
-	fs.StringVar(&cfg.Output, "output", "", "Output MP4 file path (required)")
-	fs.StringVar(&cfg.Output, "o", "", "Output MP4 file path (required) [shorthand]")
-	fs.IntVar(&cfg.FPS, "fps", 30, "Frames per second")
-	fs.IntVar(&cfg.CRF, "crf", 23, "x264 CRF quality (lower is better)")
-	fs.StringVar(&cfg.Preset, "preset", "medium", "x264 preset (ultrafast..placebo)")
-	fs.StringVar(&cfg.BG, "bg", "black", "Background color (name or #RRGGBB)")
-	fs.BoolVar(&cfg.Overwrite, "overwrite", false, "Overwrite output if it exists")
-	fs.BoolVar(&cfg.KeepTemp, "keep-temp", false, "Keep temporary workspace")
-	fs.StringVar(&cfg.TmpDir, "tmp-dir", "", "Temporary directory to use")
-	fs.BoolVar(&cfg.Verbose, "verbose", false, "Verbose logging")
-	fs.IntVar(&cfg.Concurrency, "concurrency", 0, "Number of parallel workers (default: runtime.NumCPU())")
-	fs.IntVar(&cfg.Concurrency, "j", 0, "Number of parallel workers (default: runtime.NumCPU()) [shorthand]")
-	return cfg
-}
-
-// Finalize validates required flags and attaches the input directory.
+	cfg := &Config{flags: fs}
+	cfg.addGlobalFlags(fs)
+	cfg.addInputFlags(fs)
+	cfg.addBuildFlags(fs)
+	return cfg
+}
+
+// AddAllFlags defines every setting of every command, for code-built configs
+// and for checking config files.
+func AddAllFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addGridFlags(fs)
+	cfg.addCompareFlags(fs)
+	cfg.addPIPFlags(fs)
+	cfg.addBatchFlags(fs)
+	cfg.addWatchFlags(fs)
+	return cfg
+}
+
+// AddWatchFlags defines the build flags plus the watch flags.
+func AddWatchFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addWatchFlags(fs)
+	return cfg
+}
+
+// AddBatchFlags defines the build flags plus the batch flags.
+func AddBatchFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addBatchFlags(fs)
+	return cfg
+}
+
+// AddPIPFlags defines the build flags plus the picture-in-picture flags.
+func AddPIPFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addPIPFlags(fs)
+	return cfg
+}
+
+// AddCompareFlags defines the build flags plus the comparison flags.
+func AddCompareFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addCompareFlags(fs)
+	return cfg
+}
+
+// AddGridFlags defines the build flags plus the grid layout flags.
+func AddGridFlags(fs *flag.FlagSet) *Config {
+	cfg := AddFlags(fs)
+	cfg.addGridFlags(fs)
+	return cfg
+}
+
+// AddInputFlags defines the global flags plus those selecting input files,
+// for commands that read an input directory without encoding.
+func AddInputFlags(fs *flag.FlagSet) *Config {
+	cfg := &Config{flags: fs}
+	cfg.addGlobalFlags(fs)
+	cfg.addInputFlags(fs)
+	return cfg
+}
+
+// AddGlobalFlags defines the flags shared by every subcommand and returns a pointer to Config.
+func AddGlobalFlags(fs *flag.FlagSet) *Config {
+	cfg := &Config{flags: fs}
+	cfg.addGlobalFlags(fs)
+	return cfg
+}
+
+func (c *Config) addGlobalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.TmpDir, "tmp-dir", "", "Directory to create temporary workspaces in")
+	fs.BoolVar(&c.Verbose, "verbose", false, "Verbose logging")
+	fs.IntVar(&c.Concurrency, "concurrency", 0, "Number of parallel workers (default: runtime.NumCPU())")
+	fs.IntVar(&c.Concurrency, "j", 0, "Number of parallel workers (default: runtime.NumCPU()) [shorthand]")
+}
+
+func (c *Config) addInputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Ext, "ext", "gif,webp,apng,mp4,webm,mov,png,jpg,jpeg", "Comma-separated file extensions to read from the input directory")
+	c.MaxEntry = 1 << 30
+	fs.Var(&c.MaxEntry, "max-entry-size", "Largest file read from a .zip or .tar.gz input, such as 200M")
+}
+
+func (c *Config) addGridFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Cols, "cols", 0, "Grid columns (default: enough for a square grid)")
+	fs.IntVar(&c.Gutter, "gutter", 8, "Space between grid cells in pixels, filled with --bg")
+	fs.Float64Var(&c.GridDuration, "grid-duration", 0, "Grid length in seconds (default: the longest clip; shorter clips loop)")
+}
+
+func (c *Config) addCompareFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Stack, "stack", "horizontal", "Place the compared clips side by side (horizontal) or top and bottom (vertical)")
+	fs.BoolVar(&c.Labels, "labels", true, "Label each side of a comparison")
+	fs.StringVar(&c.LabelA, "label-a", "", "Label of the first directory (default: its name)")
+	fs.StringVar(&c.LabelB, "label-b", "", "Label of the second directory (default: its name)")
+}
+
+func (c *Config) addPIPFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.InsetPos, "inset-position", "bottom-right", "Inset corner (top-left, top-right, bottom-left, bottom-right, ...)")
+	fs.Float64Var(&c.InsetScale, "inset-scale", 0.3, "Inset width as a fraction of the canvas width")
+	fs.IntVar(&c.InsetMargin, "inset-margin", 16, "Inset distance from the frame edge in pixels")
+	fs.IntVar(&c.InsetBorder, "inset-border", 4, "Inset border width in pixels (0 = none)")
+	fs.StringVar(&c.InsetBorderColor, "inset-border-color", "white", "Inset border color")
+	fs.StringVar(&c.InsetTiming, "inset-timing", "stop", "When the inset sequence is shorter: stop (hide it) or loop")
+}
+
+func (c *Config) addWatchFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.Debounce, "debounce", 1, "Seconds the input directory must stay unchanged before a rebuild")
+	fs.BoolVar(&c.Poll, "poll", false, "Scan the input directory for changes instead of using inotify")
+	fs.Float64Var(&c.PollInterval, "poll-interval", 1, "Seconds between scans when polling")
+}
+
+func (c *Config) addBatchFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Batch, "batch", false, "Build one video per subdirectory of the input directory, into the -o directory")
+	fs.StringVar(&c.OutputTemplate, "output-template", "{dir}.mp4", "Batch output file name; {dir} is the subdirectory name")
+}
+
+func (c *Config) addBuildFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Output, "output", "", "Output MP4 file path (required)")
+	fs.StringVar(&c.Output, "o", "", "Output MP4 file path (required) [shorthand]")
+	fs.IntVar(&c.FPS, "fps", 30, "Frames per second")
+	fs.IntVar(&c.CRF, "crf", 23, "x264 CRF quality (lower is better)")
+	fs.StringVar(&c.Preset, "preset", "medium", "x264 preset (ultrafast..placebo)")
+	fs.StringVar(&c.BG, "bg", "black", "Background color (name or #RRGGBB)")
+	fs.StringVar(&c.Profile, "profile", "", "Named encoding profile (web, archive, preview, twitter, instagram-reel, or one from a config file)")
+	fs.IntVar(&c.Width, "width", 0, "Canvas width (default: widest input)")
+	fs.IntVar(&c.Height, "height", 0, "Canvas height (default: tallest input)")
+	fs.StringVar(&c.Codec, "codec", "libx264", "Video encoder (libx264 or libx265)")
+	fs.StringVar(&c.Bitrate, "bitrate", "", "Target video bitrate, e.g. 5M (overrides --crf)")
+	fs.Float64Var(&c.MaxDuration, "max-duration", 0, "Maximum output duration in seconds (0 = unlimited)")
+	fs.Float64Var(&c.StillDur, "still-duration", 3, "Seconds each still image (PNG/JPEG) is shown")
+	fs.StringVar(&c.StillMotion, "still-motion", "none", "Pan/zoom for still images: "+strings.Join(StillMotions, ", "))
+	fs.Float64Var(&c.Speed, "speed", 1, "Playback speed of every input, from 0.5 to 4")
+	fs.BoolVar(&c.Reverse, "reverse", false, "Play every input backwards")
+	fs.BoolVar(&c.Boomerang, "boomerang", false, "Play every input forwards, then backwards")
+	fs.Var(&c.TrimStart, "trim-start", "Skip the start of every input, in seconds (1.5) or frames (30f)")
+	fs.Var(&c.TrimEnd, "trim-end", "Cut every input at this time, in seconds (4) or frames (90f)")
+	fs.Float64Var(&c.SplitMaxDuration, "split-max-duration", 0, "Split the output into parts of at most this many seconds (0 = no limit)")
+	fs.Var(&c.SplitMaxSize, "split-max-size", "Split the output into parts of at most about this size, such as 50M")
+	fs.IntVar(&c.SplitEvery, "split-every", 0, "Split the output into parts of this many inputs (0 = no limit)")
+	fs.BoolVar(&c.SplitAllowCut, "split-allow-cut", false, "Cut a clip that is over a split limit on its own across parts, instead of failing")
+	fs.StringVar(&c.Manifest, "manifest", "", "JSON manifest with per-clip settings such as captions")
+	fs.BoolVar(&c.Chapters, "chapters", false, "Embed one chapter per input, titled by caption or filename")
+	fs.StringVar(&c.Subtitles, "subtitles", "", "Write a .vtt or .srt caption file naming each input during its segment")
+	fs.BoolVar(&c.EmbedSubs, "embed-subtitles", false, "Embed the captions as a mov_text subtitle track")
+	fs.BoolVar(&c.Captions, "captions", false, "Burn each clip's caption (manifest, sidecar .txt, or filename) into the video")
+	fs.StringVar(&c.WatermarkText, "watermark-text", "", "Burn this text into every frame")
+	fs.BoolVar(&c.Timecode, "timecode", false, "Burn the running output time into every frame")
+	fs.StringVar(&c.FontFile, "font-file", "", "TrueType font for text overlays (default: bundled or system font)")
+	fs.IntVar(&c.FontSize, "font-size", 24, "Text overlay font size in pixels")
+	fs.StringVar(&c.FontColor, "font-color", "white", "Text overlay color (name, #RRGGBB, or color@alpha)")
+	fs.BoolVar(&c.TextBox, "text-box", false, "Draw a translucent box behind overlay text")
+	fs.StringVar(&c.CaptionPos, "caption-position", "bottom", "Caption position (top-left, top, top-right, center, bottom-left, bottom, bottom-right)")
+	fs.StringVar(&c.WatermarkPos, "watermark-position", "top-right", "Watermark text position, and the image's unless --watermark-image-position is set")
+	fs.StringVar(&c.TimecodePos, "timecode-position", "top-left", "Timecode position")
+	fs.StringVar(&c.Watermark, "watermark", "", "Image (such as a logo PNG) drawn over every frame")
+	fs.StringVar(&c.WatermarkImagePos, "watermark-image-position", "", "Watermark image position (default: --watermark-position)")
+	fs.IntVar(&c.WatermarkMargin, "watermark-margin", 16, "Watermark image distance from the frame edge in pixels")
+	fs.Float64Var(&c.WatermarkScale, "watermark-scale", 0.15, "Watermark image width as a fraction of the canvas width (0 = original size)")
+	fs.Float64Var(&c.WatermarkOpacity, "watermark-opacity", 1, "Watermark image opacity from 0 to 1")
+	fs.StringVar(&c.Intro, "intro", "", "Text of a title card shown before the inputs")
+	fs.StringVar(&c.IntroImage, "intro-image", "", "Background image for the title card")
+	fs.StringVar(&c.Outro, "outro", "", "Text of an end card shown after the inputs")
+	fs.StringVar(&c.OutroImage, "outro-image", "", "Background image for the end card")
+	fs.Float64Var(&c.CardDuration, "card-duration", 3, "Title/end card duration in seconds")
+	fs.StringVar(&c.CardColor, "card-color", "black", "Title/end card background color")
+	fs.IntVar(&c.CardFontSize, "card-font-size", 48, "Title/end card font size in pixels")
+	fs.StringVar(&c.Audio, "audio", "", "Background audio file, trimmed to the video length")
+	fs.BoolVar(&c.AudioLoop, "audio-loop", false, "Loop the background audio when it is shorter than the video")
+	fs.Float64Var(&c.AudioFadeIn, "audio-fade-in", 0, "Background audio fade-in in seconds")
+	fs.Float64Var(&c.AudioFadeOut, "audio-fade-out", 0, "Background audio fade-out in seconds")
+	fs.Float64Var(&c.AudioVolume, "audio-volume", 1, "Background audio volume multiplier")
+	fs.StringVar(&c.AudioCodec, "audio-codec", "aac", "Audio encoder (aac or opus)")
+	fs.StringVar(&c.AudioBitrate, "audio-bitrate", "192k", "Audio bitrate")
+	fs.BoolVar(&c.AudioPerClip, "audio-per-clip", false, "Keep the sound of inputs that have it (silence for the rest)")
+	fs.BoolVar(&c.SilentAudio, "silent-audio", false, "Add a silent stereo track, for platforms that reject videos without audio")
+	fs.StringVar(&c.NotifyURL, "notify-url", "", "POST the JSON run report to this URL when the build finishes or fails")
+	fs.StringVar(&c.NotifySecret, "notify-secret", "", "Sign notifications with HMAC-SHA256 using this key (prefer GIF2VID_NOTIFY_SECRET)")
+	fs.IntVar(&c.NotifyRetries, "notify-retries", 3, "Retries of a failed notification, with doubling backoff from 1s")
+	fs.StringVar(&c.Report, "report", "", "Write a JSON run report to this path")
+	fs.BoolVar(&c.JSON, "json", false, "Print the JSON run report to stdout (logs go to stderr)")
+	fs.BoolVar(&c.Overwrite, "overwrite", false, "Overwrite output if it exists")
+	fs.BoolVar(&c.KeepTemp, "keep-temp", false, "Keep temporary workspace")
+}
+
+// Finalize validates required flags and attaches the input directory, after
+// filling unset flags from the environment and config files (see Resolve).
 func (c *Config) Finalize(args []string) error {
+	if err := c.FinalizeDir(args); err != nil {
+		return err
+	}
+	if c.Output == "" {
+		return errors.New("-o/--output is required")
+	}
+	return nil
+}
+
+// FinalizeCompare attaches the two directories of a comparison, fills
+// defaults and requires an output.
+func (c *Config) FinalizeCompare(args []string) error {
+	if len(args) != 2 {
+		return errors.New("two input directories are required")
+	}
+	c.CompareDir = args[1]
+	return c.Finalize(args[:1])
+}
+
+// FinalizePIP attaches the main and inset directories of a
+// picture-in-picture build, fills defaults and requires an output.
+func (c *Config) FinalizePIP(args []string) error {
+	if len(args) != 2 {
+		return errors.New("a main and an inset directory are required")
+	}
+	c.InsetDir = args[1]
+	return c.Finalize(args[:1])
+}
+
+// FinalizeDir attaches the input directory and fills defaults, without requiring an output.
+func (c *Config) FinalizeDir(args []string) error {
 	if len(args) == 0 {
 		return errors.New("input directory is required")
 	}
@@ -50,11 +400,190 @@ func (c *Config) Finalize(args []string) error {
 		return errors.New("only one input directory is supported")
 	}
 	c.InputDir = args[0]
-	if c.Output == "" {
-		return errors.New("-o/--output is required")
+	if err := c.Resolve(c.InputDir); err != nil {
+		return err
+	}
+	if err := c.ApplyProfile(); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate fills defaults that depend on the machine and checks option ranges.
+func (c *Config) Validate() error {
+	if c.Codec == "" {
+		c.Codec = "libx264"
+	}
+	if !Codecs[c.Codec] {
+		return fmt.Errorf("unsupported codec %q (use libx264 or libx265)", c.Codec)
+	}
+	if c.Width < 0 || c.Height < 0 || c.Width%2 != 0 || c.Height%2 != 0 {
+		return errors.New("--width and --height must be even, non-negative numbers (0 = auto)")
+	}
+	if c.MaxDuration < 0 {
+		return errors.New("--max-duration must not be negative")
+	}
+	if c.Batch && (!strings.Contains(c.OutputTemplate, "{dir}") || strings.ContainsAny(c.OutputTemplate, `/\`)) {
+		return errors.New("--output-template must be a file name containing {dir}")
+	}
+	if c.NotifyRetries < 0 {
+		return errors.New("--notify-retries must not be negative")
+	}
+	if c.NotifyURL != "" {
+		if u, err := url.Parse(c.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("--notify-url %q must be an http or https URL", c.NotifyURL)
+		}
+	}
+	if c.Debounce < 0 || c.PollInterval < 0 {
+		return errors.New("--debounce and --poll-interval must not be negative")
+	}
+	if c.SplitMaxDuration < 0 || c.SplitEvery < 0 {
+		return errors.New("--split-max-duration and --split-every must not be negative")
+	}
+	if c.Split() && (c.InsetDir != "" || len(c.InsetInputs) > 0) {
+		return ErrSplitPIP
+	}
+	if c.Cols < 0 || c.Gutter < 0 || c.Gutter%2 != 0 || c.GridDuration < 0 {
+		return errors.New("--cols, --grid-duration and --gutter must not be negative, and --gutter must be even")
+	}
+	if c.StillDur == 0 {
+		c.StillDur = 3
+	}
+	if c.StillDur < 0 {
+		return errors.New("--still-duration must be positive")
+	}
+	if c.StillMotion != "" && !slices.Contains(StillMotions, c.StillMotion) {
+		return fmt.Errorf("unknown --still-motion %q (use %s)", c.StillMotion, strings.Join(StillMotions, ", "))
+	}
+	if c.Stack != "" && !slices.Contains(Stacks, c.Stack) {
+		return fmt.Errorf("unknown --stack %q (use %s)", c.Stack, strings.Join(Stacks, ", "))
+	}
+	if c.InsetPos != "" {
+		if err := overlay.CheckPosition(c.InsetPos); err != nil {
+			return fmt.Errorf("--inset-position: %w", err)
+		}
+	}
+	if c.InsetScale < 0 || c.InsetScale > 1 || c.InsetMargin < 0 || c.InsetBorder < 0 {
+		return errors.New("--inset-scale must be between 0 and 1, and --inset-margin and --inset-border must not be negative")
+	}
+	if c.InsetTiming != "" && !slices.Contains(InsetTimings, c.InsetTiming) {
+		return fmt.Errorf("unknown --inset-timing %q (use %s)", c.InsetTiming, strings.Join(InsetTimings, ", "))
+	}
+	if c.Speed == 0 {
+		c.Speed = 1
+	}
+	if c.Speed < inputs.MinSpeed || c.Speed > inputs.MaxSpeed {
+		return fmt.Errorf("--speed must be between %g and %g", inputs.MinSpeed, inputs.MaxSpeed)
+	}
+	if !c.TrimEnd.IsZero() && c.TrimStart.Frames == c.TrimEnd.Frames && c.TrimEnd.Sec(1) <= c.TrimStart.Sec(1) {
+		return fmt.Errorf("--trim-end must be after --trim-start")
+	}
+	if c.Subtitles != "" {
+		if _, err := subtitles.FormatFor(c.Subtitles); err != nil {
+			return fmt.Errorf("--subtitles: %w", err)
+		}
+	}
+	if c.HasCards() {
+		if c.CardDuration <= 0 {
+			return errors.New("--card-duration must be positive")
+		}
+		if (c.Intro != "" || c.Outro != "") && c.CardFontSize <= 0 {
+			return errors.New("--card-font-size must be positive")
+		}
+	}
+	if c.Captions || c.WatermarkText != "" || c.Timecode {
+		if c.FontSize <= 0 {
+			return errors.New("--font-size must be positive")
+		}
+		for _, p := range [][2]string{{"caption-position", c.CaptionPos}, {"watermark-position", c.WatermarkPos}, {"timecode-position", c.TimecodePos}} {
+			if err := overlay.CheckPosition(p[1]); err != nil {
+				return fmt.Errorf("--%s: %w", p[0], err)
+			}
+		}
+	}
+	if c.Watermark != "" {
+		if c.WatermarkMargin < 0 {
+			return errors.New("--watermark-margin must not be negative")
+		}
+		if c.WatermarkScale < 0 || c.WatermarkScale > 1 {
+			return errors.New("--watermark-scale must be between 0 and 1")
+		}
+		if c.WatermarkOpacity <= 0 || c.WatermarkOpacity > 1 {
+			return errors.New("--watermark-opacity must be greater than 0 and at most 1")
+		}
+		if err := overlay.CheckPosition(c.ImagePosition()); err != nil {
+			return fmt.Errorf("--watermark-image-position: %w", err)
+		}
+		if c.WatermarkText != "" && c.ImagePosition() == c.WatermarkPos {
+			return fmt.Errorf("the watermark image and --watermark-text would both be drawn at %s (set --watermark-image-position)", c.WatermarkPos)
+		}
+	}
+	if c.HasAudio() {
+		if _, ok := AudioCodecs[c.AudioCodec]; !ok {
+			return fmt.Errorf("unsupported audio codec %q (use aac or opus)", c.AudioCodec)
+		}
+		if c.AudioBitrate == "" {
+			return errors.New("--audio-bitrate must not be empty")
+		}
+	}
+	if c.SilentAudio && (c.Audio != "" || c.AudioPerClip) {
+		return errors.New("--silent-audio cannot be combined with --audio or --audio-per-clip")
+	}
+	if c.Audio != "" && (c.AudioFadeIn < 0 || c.AudioFadeOut < 0 || c.AudioVolume < 0) {
+		return errors.New("--audio-fade-in, --audio-fade-out and --audio-volume must not be negative")
 	}
 	if c.Concurrency <= 0 {
 		c.Concurrency = runtime.NumCPU()
 	}
 	return nil
 }
+
+// HasText reports whether any text overlay or card text is enabled.
+func (c *Config) HasText() bool {
+	return c.Captions || c.WatermarkText != "" || c.Timecode || c.Intro != "" || c.Outro != "" ||
+		(c.Labels && c.CompareDir != "")
+}
+
+// ImagePosition returns where the watermark image is drawn.
+func (c *Config) ImagePosition() string {
+	if c.WatermarkImagePos != "" {
+		return c.WatermarkImagePos
+	}
+	return c.WatermarkPos
+}
+
+// Split reports whether a --split-* limit writes the output as parts.
+func (c *Config) Split() bool {
+	return c.SplitMaxDuration > 0 || c.SplitMaxSize > 0 || c.SplitEvery > 0
+}
+
+// ErrSplitPIP rejects --split-* for a picture-in-picture build, whose inset
+// runs across the whole output.
+var ErrSplitPIP = errors.New("--split-max-duration, --split-max-size and --split-every cannot be combined with pip, which writes one file")
+
+// HasCards reports whether a title or end card is enabled.
+func (c *Config) HasCards() bool {
+	return c.Intro != "" || c.IntroImage != "" || c.Outro != "" || c.OutroImage != ""
+}
+
+// HasAudio reports whether the output gets an audio track.
+func (c *Config) HasAudio() bool {
+	return c.Audio != "" || c.AudioPerClip || c.SilentAudio
+}
+
+// Extensions returns the input extensions from Ext, lower-cased and with a
+// leading dot (".gif").
+func (c *Config) Extensions() []string {
+	var exts []string
+	for _, e := range strings.Split(c.Ext, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		exts = append(exts, e)
+	}
+	return exts
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"testing"
 )
@@ -40,6 +41,13 @@ func TestConfigFinalize(t *testing.T) {
 			t.Errorf("expected InputDir 'indir', got %q", cfg.InputDir)
 		}
 	})
+
+	t.Run("pip with split", func(t *testing.T) {
+		cfg := &Config{Output: "out.mp4", SplitEvery: 2}
+		if err := cfg.FinalizePIP([]string{"main", "inset"}); !errors.Is(err, ErrSplitPIP) {
+			t.Errorf("FinalizePIP = %v; want ErrSplitPIP", err)
+		}
+	})
 }
 
 func TestAddFlags(t *testing.T) {
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseFile reads a gif2vid.yaml or .gif2vid.toml file into a flat map of
+// dotted keys ("fps", "profiles.web.crf") to raw string values. Only the
+// subset of YAML/TOML needed for settings is supported: scalars and nested
+// tables, no lists.
+func ParseFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vals map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		vals, err = parseYAML(string(data))
+	case ".toml":
+		vals, err = parseTOML(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported config file type: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vals, nil
+}
+
+// normalizeKey maps "keep_temp" and "Keep-Temp" to the flag name "keep-temp".
+func normalizeKey(k string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(k)), "_", "-")
+}
+
+func parseYAML(src string) (map[string]string, error) {
+	type level struct {
+		indent int
+		prefix string
+	}
+	out := map[string]string{}
+	stack := []level{{indent: -1}}
+	sc := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; sc.Scan(); n++ {
+		raw := stripComment(sc.Text())
+		if strings.TrimSpace(raw) == "" || strings.TrimSpace(raw) == "---" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "- ") || line == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported", n)
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		full := stack[len(stack)-1].prefix + normalizeKey(key)
+		val = strings.TrimSpace(val)
+		if val == "" {
+			stack = append(stack, level{indent: indent, prefix: full + "."})
+			continue
+		}
+		v, err := unquote(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out[full] = v
+	}
+	return out, sc.Err()
+}
+
+func parseTOML(src string) (map[string]string, error) {
+	out := map[string]string{}
+	prefix := ""
+	sc := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header %q", n, line)
+			}
+			var parts []string
+			for _, p := range strings.Split(strings.Trim(line, "[]"), ".") {
+				p, err := unquote(strings.TrimSpace(p))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				parts = append(parts, normalizeKey(p))
+			}
+			prefix = strings.Join(parts, ".") + "."
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", n)
+		}
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, "[") || strings.HasPrefix(val, "{") {
+			return nil, fmt.Errorf("line %d: arrays and inline tables are not supported", n)
+		}
+		v, err := unquote(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		k, err := unquote(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out[prefix+normalizeKey(k)] = v
+	}
+	return out, sc.Err()
+}
+
+// stripComment drops a trailing "# ..." that is not inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(v string) (string, error) {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return strconv.Unquote(v)
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'"), nil
+	}
+	if strings.HasPrefix(v, "\"") || strings.HasPrefix(v, "'") {
+		return "", fmt.Errorf("unterminated string %s", v)
+	}
+	return v, nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	got, err := parseYAML(`# team profile
+fps: 60
+bg: "#111"   # quoted so the hash is kept
+keep_temp: true
+tmp-dir: '/tmp/it''s'
+nested:
+  inner:
+    crf: 18
+  preset: slow
+after: x
+`)
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+	want := map[string]string{
+		"fps":              "60",
+		"bg":               "#111",
+		"keep-temp":        "true",
+		"tmp-dir":          "/tmp/it's",
+		"nested.inner.crf": "18",
+		"nested.preset":    "slow",
+		"after":            "x",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d keys %v; want %d", len(got), got, len(want))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q; want %q", k, got[k], v)
+		}
+	}
+
+	if _, err := parseYAML("inputs:\n  - a.gif\n"); err == nil {
+		t.Error("expected error for lists")
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	got, err := parseTOML(`fps = 60 # comment
+bg = "#111"
+keep_temp = true
+
+[nested.inner]
+crf = 18
+`)
+	if err != nil {
+		t.Fatalf("parseTOML failed: %v", err)
+	}
+	want := map[string]string{
+		"fps":              "60",
+		"bg":               "#111",
+		"keep-temp":        "true",
+		"nested.inner.crf": "18",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q; want %q", k, got[k], v)
+		}
+	}
+
+	if _, err := parseTOML("inputs = [\"a.gif\"]\n"); err == nil {
+		t.Error("expected error for arrays")
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	userHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userHome)
+	t.Setenv("HOME", userHome)
+	writeFile(t, filepath.Join(userHome, "gif2vid", "config.yaml"), "fps: 24\ncrf: 30\npreset: veryslow\nbg: white\n")
+
+	inputDir := t.TempDir()
+	writeFile(t, filepath.Join(inputDir, ".gif2vid.toml"), "crf = 28\npreset = \"slow\"\nbg = \"#222\"\n")
+	t.Chdir(t.TempDir())
+
+	t.Setenv("GIF2VID_PRESET", "fast")
+	t.Setenv("GIF2VID_BG", "red")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := AddFlags(fs)
+	if err := fs.Parse([]string{"--bg", "blue", "-o", "out.mp4", inputDir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Finalize(fs.Args()); err != nil {
+		t.Fatalf("Finalize failed: %v", err)
+	}
+
+	checks := []struct {
+		name, got, want, source string
+	}{
+		{"bg", cfg.BG, "blue", SourceFlag},
+		{"preset", cfg.Preset, "fast", "env GIF2VID_PRESET"},
+		{"crf", strconv.Itoa(cfg.CRF), "28", filepath.Join(inputDir, ".gif2vid.toml")},
+		{"fps", strconv.Itoa(cfg.FPS), "24", filepath.Join(userHome, "gif2vid", "config.yaml")},
+		{"overwrite", strconv.FormatBool(cfg.Overwrite), "false", SourceDefault},
+		{"output", cfg.Output, "out.mp4", SourceFlag},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q; want %q", c.name, c.got, c.want)
+		}
+		if cfg.Sources[c.name] != c.source {
+			t.Errorf("source of %s = %q; want %q", c.name, cfg.Sources[c.name], c.source)
+		}
+	}
+}
+
+func TestResolveUnknownKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "gif2vid.yaml"), "fsp: 60\n")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := AddFlags(fs)
+	if err := cfg.Resolve(dir); err == nil {
+		t.Error("expected error for unknown setting")
+	}
+}
+
+func TestResolveInvalidEnv(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIF2VID_FPS", "fast")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := AddFlags(fs)
+	if err := cfg.Resolve(t.TempDir()); err == nil {
+		t.Error("expected error for non-numeric GIF2VID_FPS")
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Profiles are the built-in named encoding profiles, keyed by setting (flag) name.
+// Config files can add profiles, or override individual values of these, under
+// a "profiles.<name>" table.
+var Profiles = map[string]map[string]string{
+	"web": {
+		"fps":    "30",
+		"crf":    "23",
+		"preset": "medium",
+		"codec":  "libx264",
+	},
+	"archive": {
+		"crf":    "16",
+		"preset": "slow",
+		"codec":  "libx264",
+	},
+	"preview": {
+		"fps":    "15",
+		"crf":    "32",
+		"preset": "veryfast",
+		"width":  "640",
+		"height": "360",
+	},
+	"twitter": {
+		"fps":          "30",
+		"width":        "1280",
+		"height":       "720",
+		"codec":        "libx264",
+		"bitrate":      "5M",
+		"max-duration": "140",
+	},
+	"instagram-reel": {
+		"fps":          "30",
+		"width":        "1080",
+		"height":       "1920",
+		"codec":        "libx264",
+		"crf":          "21",
+		"max-duration": "90",
+	},
+}
+
+const profilePrefix = "profiles."
+
+// rateControl lists the settings that choose the video rate. They are
+// alternatives (--bitrate replaces --crf), so a profile sets neither once one
+// was set above it.
+var rateControl = []string{"crf", "bitrate"}
+
+// ApplyProfile sets the values of the selected profile for every setting that
+// still has its default, so flags, environment variables and config-file
+// values all override the profile. An explicit --crf or --bitrate also
+// keeps the profile's other rate setting from applying. For a Config built in code rather than by
+// AddFlags, every profile value is applied.
+func (c *Config) ApplyProfile() error {
+	if c.Profile == "" {
+		return nil
+	}
+	p, ok := c.profiles()[c.Profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", c.Profile, strings.Join(c.ProfileNames(), ", "))
+	}
+	fs := c.flagSet()
+	if c.Sources == nil {
+		c.Sources = map[string]string{}
+	}
+	set := func(name string) bool {
+		src := c.Sources[name]
+		return src != "" && src != SourceDefault
+	}
+	rateSet := slices.ContainsFunc(rateControl, set)
+	for _, name := range sortedKeys(p) {
+		if set(name) || (rateSet && slices.Contains(rateControl, name)) {
+			continue
+		}
+		if err := fs.Set(name, p[name]); err != nil {
+			return fmt.Errorf("invalid %s value %q in profile %s: %v", name, p[name], c.Profile, err)
+		}
+		c.Sources[name] = "profile " + c.Profile
+	}
+	return nil
+}
+
+// ProfileNames lists the built-in and config-file profiles.
+func (c *Config) ProfileNames() []string {
+	return sortedKeys(c.profiles())
+}
+
+// ProfileSettings returns the merged settings of the named profile.
+func (c *Config) ProfileSettings(name string) map[string]string {
+	return c.profiles()[name]
+}
+
+// ShowProfiles prints every profile and its settings as YAML.
+func (c *Config) ShowProfiles(w io.Writer) {
+	profiles := c.profiles()
+	for _, name := range sortedKeys(profiles) {
+		fmt.Fprintf(w, "%s:\n", name)
+		for _, k := range sortedKeys(profiles[name]) {
+			fmt.Fprintf(w, "  %s: %s\n", k, showValue(profiles[name][k]))
+		}
+	}
+}
+
+// profiles merges the built-in profiles with those read from config files.
+func (c *Config) profiles() map[string]map[string]string {
+	out := map[string]map[string]string{}
+	mergeProfiles(out, Profiles)
+	mergeProfiles(out, c.fileProfiles)
+	return out
+}
+
+// mergeProfiles copies src into dst; values in src win per setting.
+func mergeProfiles(dst, src map[string]map[string]string) {
+	for name, settings := range src {
+		if dst[name] == nil {
+			dst[name] = map[string]string{}
+		}
+		for k, v := range settings {
+			dst[name][k] = v
+		}
+	}
+}
+
+// splitProfiles moves "profiles.<name>.<key>" entries out of file values and
+// merges them into dst.
+func splitProfiles(vals map[string]string, dst map[string]map[string]string) {
+	for k, v := range vals {
+		rest, ok := strings.CutPrefix(k, profilePrefix)
+		if !ok {
+			continue
+		}
+		delete(vals, k)
+		name, key, ok := strings.Cut(rest, ".")
+		if !ok {
+			// keep malformed keys so checkProfiles reports them
+			key = ""
+		}
+		if dst[name] == nil {
+			dst[name] = map[string]string{}
+		}
+		dst[name][key] = v
+	}
+}
+
+// checkProfiles rejects profile keys that are not settings a profile may set.
+func checkProfiles(profiles map[string]map[string]string, path string) error {
+	all := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(all)
+	for name, settings := range profiles {
+		for k := range settings {
+			if k == "" || k == "profile" || all.Lookup(k) == nil || canonical(k) != k {
+				return fmt.Errorf("%s: unknown setting %q in profile %s", path, k, name)
+			}
+		}
+	}
+	return nil
+}
+
+// flagSet returns the FlagSet bound to c, creating one for a Config built in
+// code. Binding resets fields to their defaults, so the current values are
+// restored afterwards.
+func (c *Config) flagSet() *flag.FlagSet {
+	if c.flags != nil {
+		return c.flags
+	}
+	saved := *c
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	c.addGlobalFlags(fs)
+	c.addBuildFlags(fs)
+	*c = saved
+	c.flags = fs
+	c.resolved = true // values set in code are never overridden from env/files
+	return fs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/crit/gif2vid/internal/inputs"
+)
+
+func TestApplyProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	t.Run("flags override profile", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg := AddFlags(fs)
+		if err := fs.Parse([]string{"--profile", "twitter", "--fps", "25", "-o", "out.mp4", t.TempDir()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Finalize(fs.Args()); err != nil {
+			t.Fatalf("Finalize failed: %v", err)
+		}
+		if cfg.FPS != 25 {
+			t.Errorf("FPS = %d; want 25 from flag", cfg.FPS)
+		}
+		if cfg.Width != 1280 || cfg.Height != 720 || cfg.MaxDuration != 140 || cfg.Bitrate != "5M" {
+			t.Errorf("profile not applied: %dx%d max=%v bitrate=%q", cfg.Width, cfg.Height, cfg.MaxDuration, cfg.Bitrate)
+		}
+		if cfg.Sources["width"] != "profile twitter" {
+			t.Errorf("source of width = %q", cfg.Sources["width"])
+		}
+	})
+
+	t.Run("explicit rate control overrides profile", func(t *testing.T) {
+		for _, c := range []struct {
+			name, profile string
+			args          []string
+			env           map[string]string
+			crf           int
+			bitrate       string
+		}{
+			{"crf flag", "twitter", []string{"--crf", "18"}, nil, 18, ""},
+			{"crf env", "twitter", nil, map[string]string{"GIF2VID_CRF": "18"}, 18, ""},
+			{"bitrate flag", "instagram-reel", []string{"--bitrate", "8M"}, nil, 23, "8M"},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				for k, v := range c.env {
+					t.Setenv(k, v)
+				}
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				cfg := AddFlags(fs)
+				args := append([]string{"--profile", c.profile, "-o", "out.mp4"}, c.args...)
+				if err := fs.Parse(append(args, t.TempDir())); err != nil {
+					t.Fatal(err)
+				}
+				if err := cfg.Finalize(fs.Args()); err != nil {
+					t.Fatalf("Finalize failed: %v", err)
+				}
+				if cfg.CRF != c.crf || cfg.Bitrate != c.bitrate {
+					t.Errorf("crf %d, bitrate %q; want %d, %q", cfg.CRF, cfg.Bitrate, c.crf, c.bitrate)
+				}
+				if cfg.Width == 0 || cfg.MaxDuration == 0 {
+					t.Errorf("rest of profile %s not applied: width %d, max-duration %v", c.profile, cfg.Width, cfg.MaxDuration)
+				}
+			})
+		}
+	})
+
+	t.Run("config file profile", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "gif2vid.yaml"), `profile: team
+crf: 20
+profiles:
+  team:
+    fps: 60
+    crf: 18
+    preset: slow
+    bg: "#111"
+  web:
+    fps: 24
+`)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg := AddFlags(fs)
+		if err := fs.Parse([]string{"-o", "out.mp4", dir}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Finalize(fs.Args()); err != nil {
+			t.Fatalf("Finalize failed: %v", err)
+		}
+		if cfg.FPS != 60 || cfg.Preset != "slow" || cfg.BG != "#111" {
+			t.Errorf("team profile not applied: fps=%d preset=%q bg=%q", cfg.FPS, cfg.Preset, cfg.BG)
+		}
+		if cfg.CRF != 20 {
+			t.Errorf("CRF = %d; want 20 from the file's top-level setting", cfg.CRF)
+		}
+		if got := cfg.ProfileSettings("web"); got["fps"] != "24" || got["crf"] != "23" {
+			t.Errorf("web profile = %v; want file fps merged over built-in values", got)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		cfg := &Config{Output: "out.mp4", Profile: "nope"}
+		if err := cfg.Finalize([]string{"indir"}); err == nil {
+			t.Error("expected error for unknown profile")
+		}
+	})
+
+	t.Run("unknown profile setting", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "gif2vid.yaml"), "profiles:\n  team:\n    fsp: 60\n")
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg := AddFlags(fs)
+		if err := cfg.Resolve(dir); err == nil {
+			t.Error("expected error for unknown profile setting")
+		}
+	})
+
+	t.Run("config built in code", func(t *testing.T) {
+		cfg := &Config{Output: "out.mp4", Profile: "preview", BG: "white"}
+		if err := cfg.Finalize([]string{"indir"}); err != nil {
+			t.Fatalf("Finalize failed: %v", err)
+		}
+		if cfg.FPS != 15 || cfg.Width != 640 || cfg.Height != 360 {
+			t.Errorf("preview profile not applied: fps=%d %dx%d", cfg.FPS, cfg.Width, cfg.Height)
+		}
+		if cfg.BG != "white" || cfg.Output != "out.mp4" {
+			t.Errorf("values outside the profile changed: bg=%q output=%q", cfg.BG, cfg.Output)
+		}
+	})
+}
+
+func TestFinalizeValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"unsupported codec", Config{Output: "o.mp4", Codec: "mpeg4"}},
+		{"odd width", Config{Output: "o.mp4", Width: 641}},
+		{"negative duration", Config{Output: "o.mp4", MaxDuration: -1}},
+		{"watermark opacity", Config{Output: "o.mp4", Watermark: "logo.png", WatermarkPos: "top-right", WatermarkOpacity: 1.5}},
+		{"card duration", Config{Output: "o.mp4", OutroImage: "end.png"}},
+		{"audio codec", Config{Output: "o.mp4", Audio: "a.mp3", AudioCodec: "mp3", AudioBitrate: "192k"}},
+		{"silent with audio", Config{Output: "o.mp4", Audio: "a.mp3", SilentAudio: true, AudioCodec: "aac", AudioBitrate: "192k"}},
+		{"subtitle format", Config{Output: "o.mp4", Subtitles: "o.ass"}},
+		{"speed", Config{Output: "o.mp4", Speed: 5}},
+		{"batch template", Config{Output: "out", Batch: true, OutputTemplate: "video.mp4"}},
+		{"batch template path", Config{Output: "out", Batch: true, OutputTemplate: "x/{dir}.mp4"}},
+		{"notify url", Config{Output: "o.mp4", NotifyURL: "ftp://example.com/hook"}},
+		{"notify retries", Config{Output: "o.mp4", NotifyRetries: -1}},
+		{"trim range", Config{Output: "o.mp4", TrimStart: inputs.Mark{Seconds: 2}, TrimEnd: inputs.Mark{Seconds: 1}}},
+		{"watermark overlap", Config{Output: "o.mp4", Watermark: "logo.png", WatermarkText: "©", FontSize: 24, CaptionPos: "bottom", WatermarkPos: "top-right", TimecodePos: "top-left", WatermarkOpacity: 1}},
+		{"text position", Config{Output: "o.mp4", Timecode: true, FontSize: 24, CaptionPos: "bottom", WatermarkPos: "top-right", TimecodePos: "middle"}},
+		{"font size", Config{Output: "o.mp4", Captions: true, CaptionPos: "bottom", WatermarkPos: "top-right", TimecodePos: "top-left"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Finalize([]string{"indir"}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a file size in bytes. As a flag it reads a number with an
+// optional K, M or G suffix (powers of 1024), such as "50M".
+type ByteSize int64
+
+var sizeUnits = []struct {
+	suffix string
+	n      int64
+}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}}
+
+// String formats the size with the largest unit that divides it, or as a
+// one-decimal approximation in the largest unit it exceeds.
+func (b ByteSize) String() string {
+	if b == 0 {
+		return ""
+	}
+	for _, u := range sizeUnits {
+		if int64(b)%u.n == 0 {
+			return strconv.FormatInt(int64(b)/u.n, 10) + u.suffix
+		}
+	}
+	for _, u := range sizeUnits {
+		if int64(b) > u.n {
+			return strconv.FormatFloat(float64(b)/float64(u.n), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Set implements flag.Value.
+func (b *ByteSize) Set(s string) error {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		*b = 0
+		return nil
+	}
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(strings.TrimSuffix(s, "B"), u.suffix); ok {
+			s, mult = n, u.n
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "B"), 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid size %q (use bytes or a K, M or G suffix)", s)
+	}
+	*b = ByteSize(v * float64(mult))
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestByteSize(t *testing.T) {
+	for in, want := range map[string]ByteSize{
+		"":      0,
+		"1000":  1000,
+		"50M":   50 << 20,
+		"50mb":  50 << 20,
+		"1.5G":  3 << 29,
+		"512KB": 512 << 10,
+	} {
+		var b ByteSize
+		if err := b.Set(in); err != nil || b != want {
+			t.Errorf("Set(%q) = %d, %v; want %d", in, b, err, want)
+		}
+	}
+	var b ByteSize
+	if err := b.Set("lots"); err == nil {
+		t.Error("expected an error")
+	}
+	for b, want := range map[ByteSize]string{50 << 20: "50M", 3 << 29: "1536M", 1000: "1000", 1500: "1.5K"} {
+		if got := b.String(); got != want {
+			t.Errorf("%d.String() = %q; want %q", int64(b), got, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to upper-cased flag names, e.g. GIF2VID_FPS, GIF2VID_KEEP_TEMP.
+const EnvPrefix = "GIF2VID_"
+
+// ProjectFileNames are searched, in order, in the input directory and then the working directory.
+var ProjectFileNames = []string{"gif2vid.yaml", "gif2vid.yml", ".gif2vid.yaml", "gif2vid.toml", ".gif2vid.toml"}
+
+// UserFileNames are searched, in order, in $XDG_CONFIG_HOME/gif2vid (or the OS equivalent).
+var UserFileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// aliases maps shorthand flags to the setting they share storage with.
+var aliases = map[string]string{
+	"o": "output",
+	"j": "concurrency",
+}
+
+// secrets are settings Show doesn't print.
+var secrets = map[string]bool{"notify-secret": true}
+
+// Source labels for values that do not come from a file or variable.
+const (
+	SourceFlag    = "flag"
+	SourceDefault = "default"
+	SourceOption  = "option" // set in code, e.g. through the gif2vid package
+)
+
+// Resolve fills every setting that was not given on the command line, in
+// precedence order: GIF2VID_* environment variables, then the project config
+// file (input directory, then working directory), then the user config file.
+// Anything left keeps its default. Sources records where each value came from.
+// It is a no-op for a Config not created by AddFlags/AddGlobalFlags.
+func (c *Config) Resolve(inputDir string) error {
+	if c.flags == nil || c.resolved {
+		return nil
+	}
+	c.resolved = true
+
+	project, projectPath, err := loadFirst(projectDirs(inputDir), ProjectFileNames)
+	if err != nil {
+		return err
+	}
+	var user map[string]string
+	userPath := ""
+	if dir, err := userConfigDir(); err == nil {
+		user, userPath, err = loadFirst([]string{filepath.Join(dir, "gif2vid")}, UserFileNames)
+		if err != nil {
+			return err
+		}
+	}
+	c.fileProfiles = map[string]map[string]string{}
+	for _, f := range []struct {
+		vals map[string]string
+		path string
+	}{{user, userPath}, {project, projectPath}} {
+		profiles := map[string]map[string]string{}
+		splitProfiles(f.vals, profiles)
+		if err := checkProfiles(profiles, f.path); err != nil {
+			return err
+		}
+		mergeProfiles(c.fileProfiles, profiles)
+		if err := checkKeys(f.vals, f.path); err != nil {
+			return err
+		}
+	}
+
+	c.Sources = map[string]string{}
+	explicit := map[string]bool{}
+	c.flags.Visit(func(f *flag.Flag) { explicit[canonical(f.Name)] = true })
+
+	var setErr error
+	c.flags.VisitAll(func(f *flag.Flag) {
+		name := canonical(f.Name)
+		if setErr != nil || name != f.Name {
+			return
+		}
+		if explicit[name] {
+			c.Sources[name] = SourceFlag
+			return
+		}
+		val, src, ok := "", SourceDefault, false
+		env := EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if val, ok = os.LookupEnv(env); ok {
+			src = "env " + env
+		} else if val, ok = project[name]; ok {
+			src = projectPath
+		} else if val, ok = user[name]; ok {
+			src = userPath
+		}
+		if ok {
+			if err := c.flags.Set(name, val); err != nil {
+				setErr = fmt.Errorf("invalid %s value %q from %s: %v", name, val, src, err)
+				return
+			}
+		}
+		c.Sources[name] = src
+	})
+	return setErr
+}
+
+// Set sets the named setting from its command-line form, such as "30" for
+// fps or "true" for captions, recording it as set in code.
+func (c *Config) Set(name, value string) error {
+	fs := c.flagSet()
+	if fs.Lookup(name) == nil || canonical(name) != name {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if err := fs.Set(name, value); err != nil {
+		return fmt.Errorf("invalid %s value %q: %v", name, value, err)
+	}
+	if c.Sources == nil {
+		c.Sources = map[string]string{}
+	}
+	c.Sources[name] = SourceOption
+	return nil
+}
+
+// Show prints the effective settings as YAML, annotated with their sources.
+func (c *Config) Show(w io.Writer) {
+	if c.flags == nil {
+		return
+	}
+	var names []string
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if canonical(f.Name) == f.Name {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+	value := func(n string) string {
+		v := c.flags.Lookup(n).Value.String()
+		if secrets[n] && v != "" {
+			return "<redacted>"
+		}
+		return showValue(v)
+	}
+	width := 0
+	for _, n := range names {
+		width = max(width, len(n)+2+len(value(n)))
+	}
+	for _, n := range names {
+		src := c.Sources[n]
+		if src == "" {
+			src = SourceDefault
+		}
+		line := n + ": " + value(n)
+		fmt.Fprintf(w, "%-*s  # %s\n", width, line, src)
+	}
+}
+
+func showValue(v string) string {
+	if v == "" || strings.ContainsAny(v, "#:'\"") || strings.TrimSpace(v) != v {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+func canonical(name string) string {
+	if full, ok := aliases[name]; ok {
+		return full
+	}
+	return name
+}
+
+// userConfigDir honors $XDG_CONFIG_HOME on every platform, falling back to the OS default.
+func userConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir, nil
+	}
+	return os.UserConfigDir()
+}
+
+func projectDirs(inputDir string) []string {
+	var dirs []string
+	if inputDir != "" {
+		dirs = append(dirs, inputDir)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	return dirs
+}
+
+// loadFirst parses the first existing file among dirs × names.
+func loadFirst(dirs, names []string) (map[string]string, string, error) {
+	for _, d := range dirs {
+		for _, n := range names {
+			p := filepath.Join(d, n)
+			if st, err := os.Stat(p); err != nil || st.IsDir() {
+				continue
+			}
+			vals, err := ParseFile(p)
+			if err != nil {
+				return nil, "", err
+			}
+			return vals, p, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// checkKeys rejects settings that no command understands, so typos surface
+// instead of being silently ignored.
+func checkKeys(vals map[string]string, path string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	all := flag.NewFlagSet("", flag.ContinueOnError)
+	AddAllFlags(all)
+	for k := range vals {
+		if all.Lookup(k) == nil || canonical(k) != k {
+			return fmt.Errorf("%s: unknown setting %q", path, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/ffmpeg"
+)
+
+// Status is the outcome of a single check.
+type Status int
+
+const (
+	OK Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	default:
+		return "FAIL"
+	}
+}
+
+// Check is one line of the doctor report.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string // actionable hint, empty when Status is OK
+}
+
+// Options controls what the doctor verifies.
+type Options struct {
+	Encoders []string // ffmpeg encoders the configured build needs
+	Filters  []string // ffmpeg filters the configured build needs
+	// CommandFilters are the filters only one command needs, by command
+	// name. A missing one is a warning, since other builds still work.
+	CommandFilters map[string][]string
+	TmpDir         string // temp workspace base; empty means os.TempDir()
+	MinFree        uint64 // minimum free bytes required in TmpDir
+	// LookPath resolves binaries; nil means ffmpeg.LookPath.
+	LookPath func(bin string) (string, error)
+}
+
+// DefaultFilters are the ffmpeg filters every build uses.
+var DefaultFilters = []string{"fps", "scale", "pad", "format"}
+
+// Run performs every check and returns them in report order.
+func Run(ctx context.Context, r ffmpeg.Runner, opts Options) []Check {
+	lookPath := opts.LookPath
+	if lookPath == nil {
+		lookPath = ffmpeg.LookPath
+	}
+
+	var checks []Check
+	haveFFmpeg := false
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		c := binaryCheck(ctx, r, lookPath, bin)
+		if c.Status != OK {
+			c.Status = Fail
+			c.Fix = fmt.Sprintf("install FFmpeg and make sure %s is on PATH", bin)
+		} else if bin == "ffmpeg" {
+			haveFFmpeg = true
+		}
+		checks = append(checks, c)
+	}
+
+	if haveFFmpeg {
+		checks = append(checks, capabilityChecks(ctx, r, "encoder", "-encoders", opts.Encoders)...)
+		checks = append(checks, capabilityChecks(ctx, r, "filter", "-filters", opts.Filters)...)
+		for _, cmd := range slices.Sorted(maps.Keys(opts.CommandFilters)) {
+			var names []string
+			for _, n := range opts.CommandFilters[cmd] {
+				if !slices.Contains(opts.Filters, n) {
+					names = append(names, n)
+				}
+			}
+			for _, c := range capabilityChecks(ctx, r, "filter", "-filters", names) {
+				if c.Name == "filters" {
+					continue // already reported for opts.Filters
+				}
+				if c.Status == Fail {
+					c.Status = Warn
+					c.Detail += "; gif2vid " + cmd + " needs it"
+				}
+				checks = append(checks, c)
+			}
+		}
+	}
+
+	checks = append(checks, magickChecks(ctx, r, lookPath)...)
+	checks = append(checks, tmpChecks(opts.TmpDir, opts.MinFree)...)
+	return checks
+}
+
+// Failed reports whether any check failed, or warned when strict is set.
+func Failed(checks []Check, strict bool) bool {
+	for _, c := range checks {
+		if c.Status == Fail || (strict && c.Status == Warn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a human readable report.
+func Print(w io.Writer, checks []Check) {
+	width := 0
+	for _, c := range checks {
+		width = max(width, len(c.Name))
+	}
+	for _, c := range checks {
+		fmt.Fprintf(w, "%-4s  %-*s  %s\n", c.Status, width, c.Name, c.Detail)
+		if c.Fix != "" {
+			fmt.Fprintf(w, "      %-*s  fix: %s\n", width, "", c.Fix)
+		}
+	}
+}
+
+func binaryCheck(ctx context.Context, r ffmpeg.Runner, lookPath func(string) (string, error), bin string) Check {
+	p, err := lookPath(bin)
+	if err != nil {
+		return Check{Name: bin, Status: Fail, Detail: "not found in PATH"}
+	}
+	v, err := ffmpeg.Version(ctx, r, bin)
+	if err != nil {
+		return Check{Name: bin, Status: Fail, Detail: fmt.Sprintf("%s does not run: %v", p, firstLine(err.Error()))}
+	}
+	return Check{Name: bin, Status: OK, Detail: fmt.Sprintf("%s (%s)", v, p)}
+}
+
+// capabilityChecks verifies that every name appears in `ffmpeg -hide_banner <listFlag>`.
+func capabilityChecks(ctx context.Context, r ffmpeg.Runner, kind, listFlag string, names []string) []Check {
+	if len(names) == 0 {
+		return nil
+	}
+	stdout, stderr, err := r.Run(ctx, "ffmpeg", []string{"-hide_banner", listFlag})
+	if err != nil {
+		return []Check{{
+			Name:   kind + "s",
+			Status: Fail,
+			Detail: fmt.Sprintf("ffmpeg %s failed: %s", listFlag, firstLine(string(stderr))),
+			Fix:    "check that the ffmpeg binary on PATH is a working build",
+		}}
+	}
+	have := parseCapabilities(string(stdout))
+	var checks []Check
+	for _, n := range names {
+		c := Check{Name: kind + " " + n, Status: OK, Detail: "available"}
+		if !have[n] {
+			c.Status = Fail
+			c.Detail = "not compiled into ffmpeg"
+			if kind == "encoder" {
+				c.Fix = fmt.Sprintf("install an ffmpeg build configured with --enable-%s (and --enable-gpl if required)", n)
+			} else {
+				c.Fix = fmt.Sprintf("install a full ffmpeg build that includes the %s filter", n)
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// parseCapabilities extracts names from `ffmpeg -encoders`/`-filters` listings.
+// Entry lines are "<flags> <name> <description>"; legend lines are "<flags> = <meaning>".
+func parseCapabilities(out string) map[string]bool {
+	names := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] == "=" {
+			continue
+		}
+		names[fields[1]] = true
+	}
+	return names
+}
+
+func magickChecks(ctx context.Context, r ffmpeg.Runner, lookPath func(string) (string, error)) []Check {
+	bin := ""
+	if _, err := lookPath("magick"); err == nil {
+		bin = "magick"
+	} else if _, err := lookPath("convert"); err == nil {
+		bin = "convert"
+	}
+	if bin == "" {
+		return []Check{{
+			Name:   "imagemagick",
+			Status: Warn,
+			Detail: "not found (optional WebP fallback disabled)",
+			Fix:    "install ImageMagick with WebP support for files ffmpeg cannot decode",
+		}}
+	}
+
+	stdout, stderr, err := r.Run(ctx, bin, []string{"-version"})
+	if err != nil {
+		return []Check{{
+			Name:   "imagemagick",
+			Status: Warn,
+			Detail: fmt.Sprintf("%s -version failed: %s", bin, firstLine(string(stderr))),
+			Fix:    "reinstall ImageMagick",
+		}}
+	}
+	out := string(stdout)
+	checks := []Check{{Name: "imagemagick", Status: OK, Detail: strings.TrimPrefix(firstLine(out), "Version: ")}}
+
+	webp := Check{Name: "imagemagick webp", Status: OK, Detail: "WebP delegate available"}
+	if !hasDelegate(out, "webp") {
+		webp.Status = Warn
+		webp.Detail = "WebP delegate missing"
+		webp.Fix = "install ImageMagick built with libwebp (e.g. `brew install imagemagick` or the imagemagick + libwebp packages)"
+	}
+	checks = append(checks, webp)
+
+	stdout, _, err = r.Run(ctx, bin, []string{"-list", "policy"})
+	if err != nil {
+		return checks
+	}
+	policy := Check{Name: "imagemagick policy", Status: OK, Detail: "GIF, WebP and PNG allowed"}
+	if blocked := blockedCoders(string(stdout), "GIF", "WEBP", "PNG"); len(blocked) > 0 {
+		policy.Status = Warn
+		policy.Detail = "security policy blocks " + strings.Join(blocked, ", ")
+		policy.Fix = "relax the <policy domain=\"coder\"> entries in ImageMagick's policy.xml"
+	}
+	return append(checks, policy)
+}
+
+// hasDelegate reports whether `-version` output lists the delegate.
+func hasDelegate(versionOut, delegate string) bool {
+	for _, line := range strings.Split(versionOut, "\n") {
+		if !strings.HasPrefix(line, "Delegates") {
+			continue
+		}
+		_, list, _ := strings.Cut(line, ":")
+		for _, d := range strings.Fields(list) {
+			if strings.EqualFold(d, delegate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// blockedCoders parses `-list policy` output and returns which of coders are
+// denied by a coder policy with rights None.
+func blockedCoders(policyOut string, coders ...string) []string {
+	type policy struct{ domain, rights, pattern string }
+	var policies []policy
+	for _, line := range strings.Split(policyOut, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch strings.ToLower(key) {
+		case "policy":
+			policies = append(policies, policy{domain: strings.ToLower(val)})
+		case "rights":
+			if n := len(policies); n > 0 {
+				policies[n-1].rights = strings.ToLower(val)
+			}
+		case "pattern":
+			if n := len(policies); n > 0 {
+				policies[n-1].pattern = strings.ToUpper(val)
+			}
+		}
+	}
+
+	var blocked []string
+	for _, c := range coders {
+		for _, p := range policies {
+			if p.domain == "coder" && p.rights == "none" && patternMatches(p.pattern, c) {
+				blocked = append(blocked, c)
+				break
+			}
+		}
+	}
+	return blocked
+}
+
+// patternMatches handles the pattern forms used in policy.xml: "*", "WEBP" and "{GIF,WEBP}".
+func patternMatches(pattern, coder string) bool {
+	if pattern == "*" {
+		return true
+	}
+	for _, p := range strings.Split(strings.Trim(pattern, "{}"), ",") {
+		if strings.TrimSpace(p) == coder {
+			return true
+		}
+	}
+	return false
+}
+
+func tmpChecks(dir string, minFree uint64) []Check {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	write := Check{Name: "temp dir", Status: OK, Detail: dir + " is writable"}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		write.Status = Fail
+		write.Detail = err.Error()
+		write.Fix = "pass --tmp-dir with a writable directory"
+		return []Check{write}
+	}
+	f, err := os.CreateTemp(dir, "gif2vid-doctor-*")
+	if err != nil {
+		write.Status = Fail
+		write.Detail = err.Error()
+		write.Fix = "pass --tmp-dir with a writable directory"
+		return []Check{write}
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	space := Check{Name: "temp space", Status: OK}
+	free, err := freeBytes(dir)
+	switch {
+	case err != nil:
+		space.Status = Warn
+		space.Detail = "could not determine free space: " + err.Error()
+	case free < minFree:
+		space.Status = Fail
+		space.Detail = fmt.Sprintf("%s free, need at least %s", humanBytes(free), humanBytes(minFree))
+		space.Fix = "free up disk space or pass --tmp-dir on a larger volume"
+	default:
+		space.Detail = humanBytes(free) + " free"
+	}
+	return []Check{write, space}
+}
+
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
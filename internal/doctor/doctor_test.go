@@ -0,0 +1,179 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type mockRunner struct {
+	mockRun func(ctx context.Context, name string, args []string) ([]byte, []byte, error)
+}
+
+func (m *mockRunner) Run(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+	return m.mockRun(ctx, name, args)
+}
+
+const encodersOut = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC (codec h264)
+ V....D libx265              libx265 H.265 / HEVC (codec hevc)
+`
+
+const filtersOut = `Filters:
+  T.. = Timeline support
+  | = Source or sink filter
+ ... fps               V->V       Force constant framerate.
+ TSC scale             V->V       Scale the input video size and/or convert the image format.
+ ... pad               V->V       Pad the input video.
+ ... format            V->V       Convert the input video to one of the specified pixel formats.
+`
+
+const magickVersionOut = `Version: ImageMagick 7.1.1-29 Q16-HDRI aarch64 21991
+Delegates (built-in): bzlib fontconfig freetype jng jpeg lcms png tiff webp xml zlib
+`
+
+const policyOut = `Path: /etc/ImageMagick-7/policy.xml
+  Policy: Coder
+    rights: None
+    pattern: {PS,PDF,WEBP}
+  Policy: Resource
+    name: disk
+    value: 1GiB
+`
+
+func fakeEnv(missing ...string) (*mockRunner, func(string) (string, error)) {
+	lookPath := func(bin string) (string, error) {
+		for _, m := range missing {
+			if m == bin {
+				return "", errors.New("not found")
+			}
+		}
+		return "/usr/bin/" + bin, nil
+	}
+	mr := &mockRunner{mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+		switch strings.Join(append([]string{name}, args...), " ") {
+		case "ffmpeg -version":
+			return []byte("ffmpeg version 6.1.1 Copyright (c) 2000-2023\nbuilt with gcc\n"), nil, nil
+		case "ffprobe -version":
+			return []byte("ffprobe version 6.1.1\n"), nil, nil
+		case "ffmpeg -hide_banner -encoders":
+			return []byte(encodersOut), nil, nil
+		case "ffmpeg -hide_banner -filters":
+			return []byte(filtersOut), nil, nil
+		case "magick -version":
+			return []byte(magickVersionOut), nil, nil
+		case "magick -list policy":
+			return []byte(policyOut), nil, nil
+		}
+		return nil, []byte("unexpected"), errors.New("unexpected command")
+	}}
+	return mr, lookPath
+}
+
+func find(t *testing.T, checks []Check, name string) Check {
+	t.Helper()
+	for _, c := range checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("check %q not in report", name)
+	return Check{}
+}
+
+func TestRun(t *testing.T) {
+	mr, lookPath := fakeEnv()
+	checks := Run(context.Background(), mr, Options{
+		Encoders: []string{"libx264", "libvpx-vp9"},
+		Filters:  []string{"fps", "scale", "xfade"},
+		TmpDir:   t.TempDir(),
+		LookPath: lookPath,
+	})
+
+	if c := find(t, checks, "ffmpeg"); c.Status != OK || !strings.Contains(c.Detail, "6.1.1") {
+		t.Errorf("ffmpeg check = %+v", c)
+	}
+	if c := find(t, checks, "encoder libx264"); c.Status != OK {
+		t.Errorf("libx264 check = %+v", c)
+	}
+	if c := find(t, checks, "encoder libvpx-vp9"); c.Status != Fail || c.Fix == "" {
+		t.Errorf("libvpx-vp9 check = %+v; want Fail with fix", c)
+	}
+	if c := find(t, checks, "filter xfade"); c.Status != Fail {
+		t.Errorf("xfade check = %+v; want Fail", c)
+	}
+	if c := find(t, checks, "imagemagick webp"); c.Status != OK {
+		t.Errorf("webp delegate check = %+v", c)
+	}
+	if c := find(t, checks, "imagemagick policy"); c.Status != Warn || !strings.Contains(c.Detail, "WEBP") {
+		t.Errorf("policy check = %+v; want Warn mentioning WEBP", c)
+	}
+	if c := find(t, checks, "temp dir"); c.Status != OK {
+		t.Errorf("temp dir check = %+v", c)
+	}
+	if !Failed(checks, false) {
+		t.Error("Failed should report the missing encoder/filter")
+	}
+}
+
+func TestRunCommandFilters(t *testing.T) {
+	mr, lookPath := fakeEnv()
+	checks := Run(context.Background(), mr, Options{
+		Filters:        DefaultFilters,
+		CommandFilters: map[string][]string{"grid": {"xstack"}, "pip": {"pad"}},
+		TmpDir:         t.TempDir(),
+		LookPath:       lookPath,
+	})
+	if c := find(t, checks, "filter xstack"); c.Status != Warn || !strings.Contains(c.Detail, "gif2vid grid") {
+		t.Errorf("xstack check = %+v; want Warn naming the command", c)
+	}
+	n := 0
+	for _, c := range checks {
+		if c.Name == "filter pad" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("pad checked %d times; want once", n)
+	}
+	if Failed(checks, false) {
+		t.Error("a filter only one command needs should not fail the report")
+	}
+}
+
+func TestRunMissingTools(t *testing.T) {
+	mr, lookPath := fakeEnv("ffprobe", "magick", "convert")
+	checks := Run(context.Background(), mr, Options{
+		Encoders: []string{"libx264"},
+		TmpDir:   t.TempDir(),
+		LookPath: lookPath,
+	})
+	if c := find(t, checks, "ffprobe"); c.Status != Fail || c.Fix == "" {
+		t.Errorf("ffprobe check = %+v; want Fail with fix", c)
+	}
+	if c := find(t, checks, "imagemagick"); c.Status != Warn {
+		t.Errorf("imagemagick check = %+v; want Warn", c)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	warnOnly := []Check{{Name: "a", Status: OK}, {Name: "b", Status: Warn}}
+	if Failed(warnOnly, false) {
+		t.Error("warnings should not fail without strict")
+	}
+	if !Failed(warnOnly, true) {
+		t.Error("warnings should fail with strict")
+	}
+}
+
+func TestTmpChecksMinFree(t *testing.T) {
+	checks := tmpChecks(t.TempDir(), 1<<62)
+	space := find(t, checks, "temp space")
+	if space.Status == OK {
+		t.Errorf("temp space = %+v; want non-OK for an impossible minimum", space)
+	}
+}
@@ -34,6 +34,18 @@ func LookPath(bin string) (string, error) {
 	return p, nil
 }
 
+// FindMagick returns the ImageMagick binary to use for fallbacks: "magick"
+// (v7), "convert" (v6), or "" when neither is installed.
+func FindMagick() string {
+	if _, err := LookPath("magick"); err == nil {
+		return "magick"
+	}
+	if _, err := LookPath("convert"); err == nil {
+		return "convert"
+	}
+	return ""
+}
+
 // PrettyCmd renders a friendly representation of a command for errors/logs.
 func PrettyCmd(name string, args []string) string {
 	var b strings.Builder
@@ -51,3 +63,13 @@ func PrettyCmd(name string, args []string) string {
 	}
 	return b.String()
 }
+
+// Version returns the first line of `bin -version`, e.g. "ffmpeg version 6.1.1 Copyright ...".
+func Version(ctx context.Context, r Runner, bin string) (string, error) {
+	stdout, stderr, err := r.Run(ctx, bin, []string{"-version"})
+	if err != nil {
+		return "", fmt.Errorf("%s -version failed: %v\n%s", bin, err, string(stderr))
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(stdout)), "\n")
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,206 @@
+package inputs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IsArchive reports whether path is a .zip, .tar, .tar.gz or .tgz archive,
+// judging by its name.
+func IsArchive(path string) bool {
+	p := strings.ToLower(path)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractArchive extracts the entries of archive with one of exts into dir
+// and returns their absolute paths, ordered by their path in the archive.
+// Entries keep their folders, so that files of the same name in different
+// folders don't collide.
+//
+// Only regular files are extracted: folders, symlinks and other special
+// entries, and hidden entries such as __MACOSX/ metadata, are skipped. An
+// entry whose path leaves dir, or that is larger than maxSize bytes (0 is
+// unlimited), fails the extraction.
+func ExtractArchive(archive, dir string, exts []string, maxSize int64) ([]string, error) {
+	allowedExt := map[string]bool{}
+	for _, e := range exts {
+		allowedExt[strings.ToLower(e)] = true
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	x := &extractor{dir: dir, exts: allowedExt, maxSize: maxSize}
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		err = x.zip(archive)
+	} else {
+		err = x.tar(archive)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("archive %s: %w", archive, err)
+	}
+	if len(x.files) == 0 {
+		return nil, fmt.Errorf("no supported files (%s) found in: %s", strings.Join(exts, ", "), archive)
+	}
+
+	slices.SortFunc(x.files, func(a, b entry) int { return strings.Compare(a.name, b.name) })
+	out := make([]string, len(x.files))
+	for i, f := range x.files {
+		out[i] = f.path
+	}
+	return out, nil
+}
+
+// extractor writes the wanted entries of an archive into dir.
+type extractor struct {
+	dir     string
+	exts    map[string]bool
+	maxSize int64
+	files   []entry
+}
+
+// entry is an extracted file: its name in the archive and its path on disk.
+type entry struct {
+	name, path string
+}
+
+func (x *extractor) zip(archive string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		name, ok, err := x.want(f.Name, int64(f.UncompressedSize64))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		err = x.write(name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if p := strings.ToLower(archive); strings.HasSuffix(p, ".gz") || strings.HasSuffix(p, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, ok, err := x.want(hdr.Name, hdr.Size)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := x.write(name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// want reports whether the entry name of the given size should be
+// extracted, returning its cleaned name, and fails for entries that are
+// unsafe to extract.
+func (x *extractor) want(name string, size int64) (string, bool, error) {
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", false, fmt.Errorf("entry %s is outside the archive", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return "", false, nil
+		}
+	}
+	if !x.exts[strings.ToLower(path.Ext(name))] {
+		return "", false, nil
+	}
+	if x.maxSize > 0 && size > x.maxSize {
+		return "", false, fmt.Errorf("entry %s is larger than %d bytes", name, x.maxSize)
+	}
+	return name, true, nil
+}
+
+// write copies the entry name from r into dir, failing if it turns out
+// larger than its header said and over the size limit.
+func (x *extractor) write(name string, r io.Reader) error {
+	p := filepath.Join(x.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("entry %s appears more than once", name)
+	}
+	if err != nil {
+		return err
+	}
+	if x.maxSize > 0 {
+		r = io.LimitReader(r, x.maxSize+1)
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && x.maxSize > 0 && n > x.maxSize {
+		err = fmt.Errorf("entry %s is larger than %d bytes", name, x.maxSize)
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return err
+	}
+	x.files = append(x.files, entry{name: name, path: p})
+	return nil
+}
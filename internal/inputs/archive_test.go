@@ -0,0 +1,175 @@
+package inputs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEntry is an archive entry; link makes it a symlink to that target.
+type testEntry struct {
+	name, body, link string
+}
+
+func writeZip(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		if e.link != "" {
+			h.SetMode(os.ModeSymlink | 0o777)
+			body = e.link
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func writeTarGz(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			h = &tar.Header{Name: e.name, Linkname: e.link, Mode: 0o777, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	f.Close()
+}
+
+func TestExtractArchive(t *testing.T) {
+	entries := []testEntry{
+		{name: "pack/b.webp", body: "b"},
+		{name: "pack/a.gif", body: "a"},
+		{name: "pack/notes.txt", body: "skip"},
+		{name: "pack/.hidden.gif", body: "skip"},
+		{name: "__MACOSX/pack/._a.gif", body: "skip"},
+		{name: "pack/link.gif", link: "/etc/passwd"},
+		{name: "pack/more/a.GIF", body: "nested"},
+	}
+	for _, tc := range []struct {
+		name  string
+		write func(*testing.T, string, []testEntry)
+	}{
+		{"pack.zip", writeZip},
+		{"pack.tar.gz", writeTarGz},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			archive := filepath.Join(tmp, tc.name)
+			tc.write(t, archive, entries)
+			dir := filepath.Join(tmp, "out")
+
+			got, err := ExtractArchive(archive, dir, []string{".gif", ".webp"}, 0)
+			if err != nil {
+				t.Fatalf("ExtractArchive failed: %v", err)
+			}
+			want := []string{
+				filepath.Join(dir, "pack", "a.gif"),
+				filepath.Join(dir, "pack", "b.webp"),
+				filepath.Join(dir, "pack", "more", "a.GIF"),
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got %q; want %q", got, want)
+			}
+			if b, _ := os.ReadFile(want[2]); string(b) != "nested" {
+				t.Errorf("nested entry holds %q", b)
+			}
+			if _, err := os.Lstat(filepath.Join(dir, "pack", "link.gif")); !os.IsNotExist(err) {
+				t.Errorf("symlink entry was extracted: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []testEntry
+		maxSize int64
+		want    string
+	}{
+		{"parent path", []testEntry{{name: "../evil.gif", body: "x"}}, 0, "outside"},
+		{"nested parent path", []testEntry{{name: "pack/../../evil.gif", body: "x"}}, 0, "outside"},
+		{"absolute path", []testEntry{{name: "/tmp/evil.gif", body: "x"}}, 0, "outside"},
+		{"backslash path", []testEntry{{name: `..\evil.gif`, body: "x"}}, 0, "outside"},
+		{"oversize", []testEntry{{name: "big.gif", body: "0123456789"}}, 4, "larger"},
+		{"duplicate", []testEntry{{name: "a.gif", body: "1"}, {name: "a.gif", body: "2"}}, 0, "more than once"},
+		{"no inputs", []testEntry{{name: "readme.txt", body: "x"}}, 0, "no supported files"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			archive := filepath.Join(tmp, "pack.zip")
+			writeZip(t, archive, tc.entries)
+			dir := filepath.Join(tmp, "sub", "out")
+
+			_, err := ExtractArchive(archive, dir, []string{".gif"}, tc.maxSize)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v; want it to mention %q", err, tc.want)
+			}
+			if _, err := os.Stat(filepath.Join(tmp, "evil.gif")); err == nil {
+				t.Error("entry was written outside the extraction directory")
+			}
+			if _, err := os.Stat(filepath.Join(tmp, "sub", "evil.gif")); err == nil {
+				t.Error("entry was written outside the extraction directory")
+			}
+		})
+	}
+}
+
+func TestExtractArchiveAtLimit(t *testing.T) {
+	tmp := t.TempDir()
+	archive := filepath.Join(tmp, "pack.tar.gz")
+	writeTarGz(t, archive, []testEntry{{name: "a.gif", body: "abc"}})
+	got, err := ExtractArchive(archive, filepath.Join(tmp, "out"), []string{".gif"}, 3)
+	if err != nil {
+		t.Fatalf("ExtractArchive failed: %v", err)
+	}
+	if b, _ := os.ReadFile(got[0]); string(b) != "abc" {
+		t.Errorf("extracted %q", b)
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for p, want := range map[string]bool{
+		"pack.zip": true, "pack.TAR.GZ": true, "pack.tgz": true, "pack.tar": true,
+		"pack": false, "a.gif": false, "pack.gz": false,
+	} {
+		if got := IsArchive(p); got != want {
+			t.Errorf("IsArchive(%q) = %v; want %v", p, got, want)
+		}
+	}
+}
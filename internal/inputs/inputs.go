@@ -7,13 +7,52 @@ import (
 	"strings"
 )
 
-var allowedExt = map[string]bool{
-	".gif":  true,
-	".webp": true,
+// DefaultExts are the file extensions discovered when --ext is not set.
+var DefaultExts = []string{".gif", ".webp", ".apng", ".mp4", ".webm", ".mov", ".png", ".jpg", ".jpeg"}
+
+// imageExt are still image formats. A .png may still be an APNG, which is
+// animated; see media.VideoCodec.
+var imageExt = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// videoExt are container formats: they may carry sound, and ImageMagick
+// cannot decode them, so they never fall back to it.
+var videoExt = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".webm": true,
+	".mov":  true,
+	".mkv":  true,
 }
 
-// GetFilesFromDir scans the directory for GIF and WebP files and returns absolute cleaned paths.
+// IsVideo reports whether path is a video container such as MP4 or WebM,
+// judging by its extension.
+func IsVideo(path string) bool {
+	return videoExt[strings.ToLower(filepath.Ext(path))]
+}
+
+// IsImage reports whether path is a PNG or JPEG file, judging by its
+// extension.
+func IsImage(path string) bool {
+	return imageExt[strings.ToLower(filepath.Ext(path))]
+}
+
+// GetFilesFromDir scans the directory for files with DefaultExts and returns absolute cleaned paths.
 func GetFilesFromDir(dirPath string) ([]string, error) {
+	return FindFiles(dirPath, DefaultExts)
+}
+
+// FindFiles scans the directory for files with one of exts (".gif", matched
+// case-insensitively) and returns absolute cleaned paths.
+func FindFiles(dirPath string, exts []string) ([]string, error) {
+	allowedExt := map[string]bool{}
+	for _, e := range exts {
+		allowedExt[strings.ToLower(e)] = true
+	}
+
 	st, err := os.Stat(dirPath)
 	if err != nil {
 		return nil, fmt.Errorf("input directory not found: %s", dirPath)
@@ -46,7 +85,7 @@ func GetFilesFromDir(dirPath string) ([]string, error) {
 	}
 
 	if len(out) == 0 {
-		return nil, fmt.Errorf("no supported files (.gif, .webp) found in: %s", dirPath)
+		return nil, fmt.Errorf("no supported files (%s) found in: %s", strings.Join(exts, ", "), dirPath)
 	}
 
 	return out, nil
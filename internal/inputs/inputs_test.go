@@ -64,3 +64,48 @@ func TestGetFilesFromDir_NotADir(t *testing.T) {
 		t.Error("expected error for non-directory")
 	}
 }
+
+func TestFindFilesExts(t *testing.T) {
+	tmp := t.TempDir()
+	for _, f := range []string{"a.gif", "b.MP4", "c.webm", "d.png", "e.mov", "f.txt"} {
+		if err := os.WriteFile(filepath.Join(tmp, f), []byte("dummy"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := GetFilesFromDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Errorf("default extensions found %d files, want 5: %v", len(got), got)
+	}
+
+	got, err = FindFiles(tmp, []string{".png", ".mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || filepath.Base(got[0]) != "b.MP4" || filepath.Base(got[1]) != "d.png" {
+		t.Errorf("FindFiles(.png, .mp4) = %v", got)
+	}
+
+	if _, err := FindFiles(tmp, []string{".apng"}); err == nil {
+		t.Error("expected error when no file matches")
+	}
+}
+
+func TestIsVideo(t *testing.T) {
+	for path, want := range map[string]bool{"a.MOV": true, "b.webm": true, "c.gif": false, "d.apng": false} {
+		if got := IsVideo(path); got != want {
+			t.Errorf("IsVideo(%q) = %v; want %v", path, got, want)
+		}
+	}
+}
+
+func TestIsImage(t *testing.T) {
+	for path, want := range map[string]bool{"a.PNG": true, "b.jpeg": true, "c.jpg": true, "d.gif": false, "e.webp": false} {
+		if got := IsImage(path); got != want {
+			t.Errorf("IsImage(%q) = %v; want %v", path, got, want)
+		}
+	}
+}
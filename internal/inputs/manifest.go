@@ -0,0 +1,112 @@
+package inputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Clip holds per-input settings from a manifest.
+type Clip struct {
+	File     string  `json:"file"`               // path relative to the manifest, or a bare filename
+	Caption  string  `json:"caption,omitempty"`  // chapter/subtitle/overlay text; defaults to the filename
+	Duration float64 `json:"duration,omitempty"` // seconds a still image is shown; defaults to --still-duration
+	Motion   string  `json:"motion,omitempty"`   // pan/zoom for a still image; defaults to --still-motion
+
+	// Playback effects; unset fields default to --speed, --reverse and --boomerang.
+	Speed     float64 `json:"speed,omitempty"`
+	Reverse   *bool   `json:"reverse,omitempty"`
+	Boomerang *bool   `json:"boomerang,omitempty"`
+
+	// Trim range in seconds or frames ("30f"); unset fields default to
+	// --trim-start and --trim-end.
+	Start Mark `json:"start,omitzero"`
+	End   Mark `json:"end,omitzero"`
+}
+
+// Playback speed limits, for --speed and manifest clips.
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 4.0
+)
+
+// Manifest is a JSON file describing per-input settings:
+//
+//	{"clips": [{"file": "intro.gif", "caption": "Opening"}]}
+type Manifest struct {
+	Clips []Clip `json:"clips"`
+
+	dir string
+}
+
+// LoadManifest reads and validates a manifest file.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("manifest not found: %s", path)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	m.dir = dir
+	seen := map[string]bool{}
+	for i, c := range m.Clips {
+		if c.File == "" {
+			return nil, fmt.Errorf("manifest %s: clip %d has no file", path, i+1)
+		}
+		if c.Duration < 0 {
+			return nil, fmt.Errorf("manifest %s: %s has a negative duration", path, c.File)
+		}
+		if c.Speed != 0 && (c.Speed < MinSpeed || c.Speed > MaxSpeed) {
+			return nil, fmt.Errorf("manifest %s: %s speed must be between %g and %g", path, c.File, MinSpeed, MaxSpeed)
+		}
+		if !c.End.IsZero() && c.Start.Frames == c.End.Frames && c.End.Sec(1) <= c.Start.Sec(1) {
+			return nil, fmt.Errorf("manifest %s: %s end must be after start", path, c.File)
+		}
+		if seen[c.File] {
+			return nil, fmt.Errorf("manifest %s: %s listed twice", path, c.File)
+		}
+		seen[c.File] = true
+	}
+	return &m, nil
+}
+
+// Lookup returns the clip settings for an absolute input path. Entries match
+// by path relative to the manifest, falling back to the bare filename.
+func (m *Manifest) Lookup(input string) (Clip, bool) {
+	if m == nil {
+		return Clip{}, false
+	}
+	for _, c := range m.Clips {
+		p := c.File
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(m.dir, p)
+		}
+		if filepath.Clean(p) == filepath.Clean(input) {
+			return c, true
+		}
+	}
+	for _, c := range m.Clips {
+		if filepath.Base(c.File) == c.File && c.File == filepath.Base(input) {
+			return c, true
+		}
+	}
+	return Clip{}, false
+}
+
+// SidecarCaption returns the trimmed contents of the .txt file next to input
+// with the same base name (clip.gif → clip.txt), or "" if there is none.
+func SidecarCaption(input string) string {
+	data, err := os.ReadFile(strings.TrimSuffix(input, filepath.Ext(input)) + ".txt")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
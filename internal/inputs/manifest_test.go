@@ -0,0 +1,71 @@
+package inputs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "manifest.json")
+	data := `{"clips": [
+		{"file": "sub/a.gif", "caption": "By path"},
+		{"file": "b.webp", "caption": "By name"}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if c, ok := m.Lookup(filepath.Join(tmp, "sub", "a.gif")); !ok || c.Caption != "By path" {
+		t.Errorf("lookup by relative path = %+v, %v", c, ok)
+	}
+	if c, ok := m.Lookup("/elsewhere/b.webp"); !ok || c.Caption != "By name" {
+		t.Errorf("lookup by filename = %+v, %v", c, ok)
+	}
+	if _, ok := m.Lookup("/elsewhere/a.gif"); ok {
+		t.Error("a path entry should not match by filename alone")
+	}
+
+	var nilManifest *Manifest
+	if _, ok := nilManifest.Lookup("x.gif"); ok {
+		t.Error("nil manifest should match nothing")
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	tmp := t.TempDir()
+	tests := map[string]string{
+		"bad json":   `{"clips": [`,
+		"no file":    `{"clips": [{"caption": "x"}]}`,
+		"duplicates": `{"clips": [{"file": "a.gif"}, {"file": "a.gif"}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tmp, name+".json")
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadManifest(path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestSidecarCaption(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("  Hello there\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := SidecarCaption(filepath.Join(tmp, "a.gif")); got != "Hello there" {
+		t.Errorf("SidecarCaption(a.gif) = %q", got)
+	}
+	if got := SidecarCaption(filepath.Join(tmp, "b.gif")); got != "" {
+		t.Errorf("SidecarCaption(b.gif) = %q; want empty", got)
+	}
+}
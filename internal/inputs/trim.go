@@ -0,0 +1,92 @@
+package inputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mark is a trim point: a time in seconds ("1.5" or "1.5s") or a frame
+// number counted from 0 ("30f"). The zero Mark is unset. It implements
+// flag.Value for --trim-start and --trim-end, and decodes from a JSON number
+// (seconds) or string in manifests.
+type Mark struct {
+	Seconds float64
+	Frame   int
+	Frames  bool // the mark is Frame rather than Seconds
+}
+
+// ParseMark parses a trim point such as "2", "2.5s" or "48f".
+func ParseMark(s string) (Mark, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Mark{}, nil
+	}
+	if n, ok := strings.CutSuffix(s, "f"); ok {
+		f, err := strconv.Atoi(n)
+		if err != nil || f < 0 {
+			return Mark{}, fmt.Errorf("invalid frame number %q", s)
+		}
+		return Mark{Frame: f, Frames: true}, nil
+	}
+	sec, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64)
+	if err != nil || sec < 0 {
+		return Mark{}, fmt.Errorf("invalid time %q (use seconds like 1.5 or a frame like 30f)", s)
+	}
+	return Mark{Seconds: sec}, nil
+}
+
+// IsZero reports whether the mark is unset (or at the very start).
+func (m Mark) IsZero() bool {
+	return m.Seconds == 0 && m.Frame == 0
+}
+
+// Sec returns the mark in seconds, converting frames at rate frames/second.
+func (m Mark) Sec(rate float64) float64 {
+	if m.Frames {
+		if rate <= 0 {
+			return 0
+		}
+		return float64(m.Frame) / rate
+	}
+	return m.Seconds
+}
+
+// String formats the mark the way ParseMark reads it.
+func (m Mark) String() string {
+	if m.Frames {
+		return strconv.Itoa(m.Frame) + "f"
+	}
+	if m.Seconds == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(m.Seconds, 'f', -1, 64) + "s"
+}
+
+// Set implements flag.Value.
+func (m *Mark) Set(s string) error {
+	v, err := ParseMark(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// UnmarshalJSON accepts a number of seconds or a string for ParseMark.
+func (m *Mark) UnmarshalJSON(b []byte) error {
+	var sec float64
+	if err := json.Unmarshal(b, &sec); err == nil {
+		if sec < 0 {
+			return fmt.Errorf("invalid time %g", sec)
+		}
+		*m = Mark{Seconds: sec}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("trim point must be seconds or a string like \"30f\"")
+	}
+	return m.Set(s)
+}
@@ -0,0 +1,41 @@
+package inputs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMark(t *testing.T) {
+	for in, want := range map[string]Mark{
+		"":     {},
+		"2":    {Seconds: 2},
+		"1.5s": {Seconds: 1.5},
+		"48f":  {Frame: 48, Frames: true},
+	} {
+		got, err := ParseMark(in)
+		if err != nil || got != want {
+			t.Errorf("ParseMark(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"-1", "abc", "-2f", "1.5f"} {
+		if _, err := ParseMark(in); err == nil {
+			t.Errorf("ParseMark(%q): expected an error", in)
+		}
+	}
+}
+
+func TestMarkJSON(t *testing.T) {
+	var c Clip
+	if err := json.Unmarshal([]byte(`{"file":"a.gif","start":1.25,"end":"40f"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Start != (Mark{Seconds: 1.25}) || c.End != (Mark{Frame: 40, Frames: true}) {
+		t.Errorf("got start %+v, end %+v", c.Start, c.End)
+	}
+	if got := c.End.Sec(20); got != 2 {
+		t.Errorf("Sec = %g; want 2", got)
+	}
+	if err := json.Unmarshal([]byte(`{"start":true}`), &c); err == nil {
+		t.Error("expected an error for a bool trim point")
+	}
+}
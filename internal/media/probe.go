@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -22,11 +21,13 @@ type ProbeResult struct {
 	} `json:"streams"`
 }
 
-// Probe returns the width and height of the first video stream in the file.
+// Probe returns the width and height of the first video stream in the file,
+// as displayed: dimensions are swapped for video rotated by 90 or 270 degrees,
+// since ffmpeg applies the rotation when decoding.
 func Probe(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, input string) (int, int, error) {
 	args := []string{
 		"-v", "error",
-		"-show_entries", "stream=width,height,codec_type",
+		"-show_entries", "stream=width,height,codec_type:stream_tags=rotate:stream_side_data=rotation",
 		"-of", "json",
 		input,
 	}
@@ -38,17 +39,60 @@ func Probe(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, input strin
 	if err := json.Unmarshal(stdout, &pr); err != nil {
 		return probeFallback(ctx, r, cfg, input)
 	}
-	for _, s := range pr.Streams {
+	for i, s := range pr.Streams {
 		if s.Width > 0 && s.Height > 0 {
+			if quarterTurn(stdout, i) {
+				return s.Height, s.Width, nil
+			}
 			return s.Width, s.Height, nil
 		}
 	}
 	return probeFallback(ctx, r, cfg, input)
 }
 
+// quarterTurn reports whether stream i of ffprobe's JSON output is rotated
+// by 90 or 270 degrees, via the display matrix or the legacy rotate tag.
+func quarterTurn(probeJSON []byte, i int) bool {
+	var out struct {
+		Streams []struct {
+			Tags struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideData []struct {
+				Rotation float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if json.Unmarshal(probeJSON, &out) != nil || i >= len(out.Streams) {
+		return false
+	}
+	s := out.Streams[i]
+	deg := 0
+	for _, sd := range s.SideData {
+		if sd.Rotation != 0 {
+			deg = int(sd.Rotation)
+		}
+	}
+	if deg == 0 && s.Tags.Rotate != "" {
+		deg, _ = strconv.Atoi(s.Tags.Rotate)
+	}
+	return (deg%180+180)%180 == 90
+}
+
+// probeFallback measures the first frame of input, decoded to a PNG of its
+// own so that concurrent probes don't share a file.
 func probeFallback(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, input string) (int, int, error) {
-	tmpDir := os.TempDir()
-	tmpFile := filepath.Join(tmpDir, "gif2vid_probe_fallback.png")
+	if cfg.TmpDir != "" {
+		if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
+			return 0, 0, err
+		}
+	}
+	f, err := os.CreateTemp(cfg.TmpDir, "gif2vid-probe-*.png")
+	if err != nil {
+		return 0, 0, err
+	}
+	tmpFile := f.Name()
+	f.Close()
 	defer os.Remove(tmpFile)
 
 	args := []string{
@@ -58,8 +102,7 @@ func probeFallback(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, inp
 		"-vframes", "1",
 		tmpFile,
 	}
-	_, _, err := r.Run(ctx, "ffmpeg", args)
-	if err != nil {
+	if _, _, err := r.Run(ctx, "ffmpeg", args); err != nil {
 		return probeMagickFallback(ctx, r, cfg, input)
 	}
 
@@ -119,3 +162,116 @@ func probeMagickFallback(ctx context.Context, r ffmpeg.Runner, cfg *config.Confi
 
 	return w, h, nil
 }
+
+// Duration returns the container duration of a media file in seconds.
+func Duration(ctx context.Context, r ffmpeg.Runner, path string) (float64, error) {
+	args := []string{
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "json",
+		path,
+	}
+	stdout, stderr, err := r.Run(ctx, "ffprobe", args)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe duration failed for %s: %v\n%s", path, err, string(stderr))
+	}
+	var out struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return 0, fmt.Errorf("ffprobe returned invalid JSON for %s: %v", path, err)
+	}
+	d, err := strconv.ParseFloat(out.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe returned no duration for %s", path)
+	}
+	return d, nil
+}
+
+// HasAudio reports whether a media file has at least one audio stream.
+func HasAudio(ctx context.Context, r ffmpeg.Runner, path string) (bool, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=codec_type",
+		"-of", "json",
+		path,
+	}
+	stdout, stderr, err := r.Run(ctx, "ffprobe", args)
+	if err != nil {
+		return false, fmt.Errorf("ffprobe audio check failed for %s: %v\n%s", path, err, string(stderr))
+	}
+	var pr ProbeResult
+	if err := json.Unmarshal(stdout, &pr); err != nil {
+		return false, fmt.Errorf("ffprobe returned invalid JSON for %s: %v", path, err)
+	}
+	for _, s := range pr.Streams {
+		if s.CodecType == "audio" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VideoCodec returns the codec name of the first video stream, such as
+// "png", "apng" or "h264".
+func VideoCodec(ctx context.Context, r ffmpeg.Runner, path string) (string, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name",
+		"-of", "json",
+		path,
+	}
+	stdout, stderr, err := r.Run(ctx, "ffprobe", args)
+	if err != nil {
+		return "", fmt.Errorf("ffprobe codec check failed for %s: %v\n%s", path, err, string(stderr))
+	}
+	var out struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return "", fmt.Errorf("ffprobe returned invalid JSON for %s: %v", path, err)
+	}
+	if len(out.Streams) == 0 {
+		return "", fmt.Errorf("no video stream in %s", path)
+	}
+	return out.Streams[0].CodecName, nil
+}
+
+// Frames returns the number of video frames in path. It counts packets, so it
+// reads the whole stream.
+func Frames(ctx context.Context, r ffmpeg.Runner, path string) (int, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-count_packets",
+		"-show_entries", "stream=nb_read_packets",
+		"-of", "json",
+		path,
+	}
+	stdout, stderr, err := r.Run(ctx, "ffprobe", args)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe frame count failed for %s: %v\n%s", path, err, string(stderr))
+	}
+	var out struct {
+		Streams []struct {
+			Packets string `json:"nb_read_packets"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return 0, fmt.Errorf("ffprobe returned invalid JSON for %s: %v", path, err)
+	}
+	if len(out.Streams) == 0 {
+		return 0, fmt.Errorf("no video stream in %s", path)
+	}
+	n, err := strconv.Atoi(out.Streams[0].Packets)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe returned no frame count for %s", path)
+	}
+	return n, nil
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/crit/gif2vid/internal/config"
 	"github.com/crit/gif2vid/internal/ffmpeg"
@@ -163,6 +168,43 @@ func TestProbe(t *testing.T) {
 		}
 	})
 
+	t.Run("concurrent fallbacks", func(t *testing.T) {
+		// Each probe's frame holds its input's number; concurrent probes must
+		// not read or remove each other's.
+		tmpCfg := &config.Config{TmpDir: t.TempDir()}
+		mr := &mockRunner{
+			mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+				last := args[len(args)-1]
+				switch {
+				case name == "ffmpeg":
+					err := os.WriteFile(last, []byte(strings.TrimSuffix(args[4], ".gif")), 0o644)
+					time.Sleep(10 * time.Millisecond)
+					return nil, nil, err
+				case strings.HasSuffix(last, ".png"):
+					n, err := os.ReadFile(last)
+					if err != nil {
+						return nil, nil, err
+					}
+					return []byte(`{"streams":[{"codec_type":"video","width":` + string(n) + `,"height":1}]}`), nil, nil
+				}
+				return []byte(`{"streams":[]}`), nil, nil
+			},
+		}
+		var wg sync.WaitGroup
+		for i := 1; i <= 8; i++ {
+			wg.Go(func() {
+				w, _, err := Probe(ctx, mr, tmpCfg, strconv.Itoa(i)+".gif")
+				if err != nil || w != i {
+					t.Errorf("probe %d = %d, %v", i, w, err)
+				}
+			})
+		}
+		wg.Wait()
+		if entries, _ := os.ReadDir(tmpCfg.TmpDir); len(entries) != 0 {
+			t.Errorf("frames left behind: %v", entries)
+		}
+	})
+
 	t.Run("magick fallback", func(t *testing.T) {
 		magickCfg := &config.Config{MagickBin: "magick"}
 		mr := &mockRunner{
@@ -182,3 +224,54 @@ func TestProbe(t *testing.T) {
 		}
 	})
 }
+
+func TestHasAudio(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want bool
+	}{
+		{`{"streams":[{"codec_type":"audio"}]}`, true},
+		{`{"streams":[]}`, false},
+	} {
+		mr := &mockRunner{mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+			return []byte(tt.out), nil, nil
+		}}
+		got, err := HasAudio(context.Background(), mr, "clip.mp4")
+		if err != nil || got != tt.want {
+			t.Errorf("HasAudio(%s) = %v, %v; want %v", tt.out, got, err, tt.want)
+		}
+	}
+}
+
+func TestProbeRotated(t *testing.T) {
+	for name, out := range map[string]string{
+		"display matrix": `{"streams":[{"codec_type":"audio"},{"codec_type":"video","width":1920,"height":1080,"side_data_list":[{"rotation":-90}]}]}`,
+		"rotate tag":     `{"streams":[{"codec_type":"video","width":1920,"height":1080,"tags":{"rotate":"270"}}]}`,
+	} {
+		mr := &mockRunner{mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+			return []byte(out), nil, nil
+		}}
+		w, h, err := Probe(context.Background(), mr, &config.Config{}, "clip.mov")
+		if err != nil || w != 1080 || h != 1920 {
+			t.Errorf("%s: got %dx%d, %v; want 1080x1920", name, w, h, err)
+		}
+	}
+}
+
+func TestVideoCodec(t *testing.T) {
+	mr := &mockRunner{mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+		return []byte(`{"streams":[{"codec_name":"apng"}]}`), nil, nil
+	}}
+	if got, err := VideoCodec(context.Background(), mr, "a.png"); err != nil || got != "apng" {
+		t.Errorf("VideoCodec = %q, %v; want apng", got, err)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	mr := &mockRunner{mockRun: func(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+		return []byte(`{"streams":[{"nb_read_packets":"42"}]}`), nil, nil
+	}}
+	if got, err := Frames(context.Background(), mr, "a.gif"); err != nil || got != 42 {
+		t.Errorf("Frames = %d, %v; want 42", got, err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package notify POSTs build outcomes to a webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+)
+
+// Headers sent with every notification. The signature is the hex HMAC-SHA256
+// of the body keyed with the secret, as "sha256=<hex>"; the delivery ID is
+// the same for every attempt, so receivers can drop repeats.
+const (
+	HeaderSignature = "X-Gif2vid-Signature"
+	HeaderDelivery  = "X-Gif2vid-Delivery"
+)
+
+// DeliveryTimeout bounds the delivery of a notification on a context from
+// Detached, retries included.
+const DeliveryTimeout = time.Minute
+
+// Detached returns the context to notify the outcome of a build run under
+// ctx with. It isn't canceled along with ctx, since a build stopped by
+// Ctrl-C or shutdown is worth reporting too, but expires after
+// DeliveryTimeout.
+func Detached(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), DeliveryTimeout)
+}
+
+// Webhook POSTs JSON payloads to URL, retrying failed deliveries.
+type Webhook struct {
+	URL     string
+	Secret  string        // signs the body when set
+	Retries int           // further attempts after a failed one
+	Backoff time.Duration // wait before the first retry, doubled after each (default 1s)
+	Client  *http.Client  // default: http.DefaultClient with a 10s timeout per attempt
+}
+
+// New returns the webhook cfg configures, or nil when --notify-url is unset.
+func New(cfg *config.Config) *Webhook {
+	if cfg.NotifyURL == "" {
+		return nil
+	}
+	return &Webhook{URL: cfg.NotifyURL, Secret: cfg.NotifySecret, Retries: cfg.NotifyRetries}
+}
+
+// Send POSTs payload as JSON. Network errors and 408, 429 and 5xx responses
+// are retried; other responses outside 2xx fail at once.
+func (w *Webhook) Send(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	delivery := hex.EncodeToString(id)
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body, delivery)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.Retries {
+			return fmt.Errorf("notify %s: %w", w.URL, err)
+		}
+		select {
+		case <-time.After(backoff << attempt):
+		case <-ctx.Done():
+			return fmt.Errorf("notify %s: %w", w.URL, err)
+		}
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth retrying.
+func (w *Webhook) post(ctx context.Context, body []byte, delivery string) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, delivery)
+	if w.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(w.Secret, body))
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	switch c := resp.StatusCode; {
+	case c >= 200 && c < 300:
+		return false, nil
+	case c == http.StatusRequestTimeout || c == http.StatusTooManyRequests || c >= 500:
+		return true, fmt.Errorf("status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("status %s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+)
+
+// receiver records deliveries and answers each with the next status in codes,
+// then 200.
+type receiver struct {
+	mu         sync.Mutex
+	codes      []int
+	bodies     []string
+	signatures []string
+	deliveries []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, string(body))
+	rc.signatures = append(rc.signatures, r.Header.Get(HeaderSignature))
+	rc.deliveries = append(rc.deliveries, r.Header.Get(HeaderDelivery))
+	if len(rc.codes) > 0 {
+		w.WriteHeader(rc.codes[0])
+		rc.codes = rc.codes[1:]
+	}
+}
+
+func TestSend(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL, Secret: "s3cret"}
+	if err := w.Send(context.Background(), map[string]any{"output": "out.mp4"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(rc.bodies) != 1 || rc.bodies[0] != `{"output":"out.mp4"}` {
+		t.Fatalf("bodies = %q", rc.bodies)
+	}
+	if want := Sign("s3cret", []byte(rc.bodies[0])); rc.signatures[0] != want {
+		t.Errorf("signature = %q; want %q", rc.signatures[0], want)
+	}
+	if rc.deliveries[0] == "" {
+		t.Error("delivery ID header missing")
+	}
+
+	// Without a secret, nothing is signed.
+	w.Secret = ""
+	if err := w.Send(context.Background(), 1); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if rc.signatures[1] != "" {
+		t.Errorf("unsigned delivery has signature %q", rc.signatures[1])
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	rc := &receiver{codes: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL, Retries: 2, Backoff: time.Millisecond}
+	if err := w.Send(context.Background(), "done"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(rc.deliveries) != 3 {
+		t.Fatalf("got %d attempts; want 3", len(rc.deliveries))
+	}
+	if rc.deliveries[0] != rc.deliveries[1] || rc.deliveries[1] != rc.deliveries[2] {
+		t.Errorf("delivery IDs differ across retries: %q", rc.deliveries)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		codes    []int
+		retries  int
+		attempts int
+	}{
+		{"retries exhausted", []int{502, 502, 502}, 2, 3},
+		{"client error", []int{400}, 3, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := &receiver{codes: tc.codes}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+
+			w := &Webhook{URL: srv.URL, Retries: tc.retries, Backoff: time.Millisecond}
+			err := w.Send(context.Background(), "done")
+			if err == nil || !strings.Contains(err.Error(), srv.URL) {
+				t.Errorf("err = %v; want a failure naming the URL", err)
+			}
+			if len(rc.bodies) != tc.attempts {
+				t.Errorf("got %d attempts; want %d", len(rc.bodies), tc.attempts)
+			}
+		})
+	}
+}
+
+func TestSendCanceled(t *testing.T) {
+	rc := &receiver{codes: []int{503}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	w := &Webhook{URL: srv.URL, Retries: 5, Backoff: time.Hour}
+	if err := w.Send(ctx, "done"); err == nil {
+		t.Error("expected error once canceled during backoff")
+	}
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.New()
+	if New(cfg) != nil {
+		t.Error("New returned a webhook without --notify-url")
+	}
+	cfg.NotifyURL, cfg.NotifySecret, cfg.NotifyRetries = "https://example.com/hook", "k", 2
+	if w := New(cfg); w == nil || w.URL != cfg.NotifyURL || w.Secret != "k" || w.Retries != 2 {
+		t.Errorf("New = %+v", w)
+	}
+}
+
+func TestDetached(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	cancelParent()
+	ctx, cancel := Detached(parent)
+	defer cancel()
+	if ctx.Err() != nil {
+		t.Errorf("detached context canceled with its parent: %v", ctx.Err())
+	}
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > DeliveryTimeout {
+		t.Errorf("deadline = %v, %v; want within %v", d, ok, DeliveryTimeout)
+	}
+}
@@ -0,0 +1,43 @@
+package overlay
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Image is how a picture such as a logo is drawn over the video.
+type Image struct {
+	Position string
+	Margin   int     // pixels between the image and the frame edge
+	Width    int     // scaled width in pixels; 0 keeps the image's size
+	Opacity  float64 // 0 (invisible) to 1 (opaque)
+
+	Border      int    // frame around the image in pixels; 0 for none
+	BorderColor string // color of the frame
+}
+
+// Filter returns a filter_complex fragment drawing the image from input
+// label src over the video from input label main. The result ends with the
+// overlaid video unlabeled, so callers can append more filters and an output
+// label.
+func (im Image) Filter(main, src string) string {
+	return im.FilterAs(main, src, "logo")
+}
+
+// FilterAs is Filter with the prepared image labeled label, so that two
+// images can be drawn in one graph.
+func (im Image) FilterAs(main, src, label string) string {
+	chain := ""
+	if im.Width > 0 {
+		chain += fmt.Sprintf("scale=%d:-1,", im.Width)
+	}
+	chain += "format=rgba"
+	if im.Border > 0 {
+		chain += fmt.Sprintf(",pad=iw+%[1]d:ih+%[1]d:%[2]d:%[2]d:color=%[3]s", 2*im.Border, im.Border, im.BorderColor)
+	}
+	if im.Opacity < 1 {
+		chain += ",colorchannelmixer=aa=" + strconv.FormatFloat(im.Opacity, 'f', -1, 64)
+	}
+	x, y := place(im.Position, "W", "w", "H", "h", im.Margin)
+	return fmt.Sprintf("[%[1]s]%[2]s[%[3]s];[%[4]s][%[3]s]overlay=x=%[5]s:y=%[6]s", src, chain, label, main, x, y)
+}
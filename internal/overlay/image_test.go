@@ -0,0 +1,35 @@
+package overlay
+
+import "testing"
+
+func TestImageFilter(t *testing.T) {
+	im := Image{Position: "bottom-right", Margin: 20, Width: 192, Opacity: 0.8}
+	got := im.Filter("0:v", "3:v")
+	want := "[3:v]scale=192:-1,format=rgba,colorchannelmixer=aa=0.8[logo];[0:v][logo]overlay=x=W-w-20:y=H-h-20"
+	if got != want {
+		t.Errorf("Filter = %q; want %q", got, want)
+	}
+
+	im = Image{Position: "top-left", Margin: 8, Width: 320, Opacity: 1, Border: 4, BorderColor: "white"}
+	got = im.Filter("0:v", "1:v")
+	want = "[1:v]scale=320:-1,format=rgba,pad=iw+8:ih+8:4:4:color=white[logo];[0:v][logo]overlay=x=8:y=8"
+	if got != want {
+		t.Errorf("Filter = %q; want %q", got, want)
+	}
+
+	im = Image{Position: "center", Opacity: 1}
+	got = im.Filter("0:v", "1:v")
+	want = "[1:v]format=rgba[logo];[0:v][logo]overlay=x=(W-w)/2:y=(H-h)/2"
+	if got != want {
+		t.Errorf("Filter = %q; want %q", got, want)
+	}
+}
+
+func TestImageFilterAs(t *testing.T) {
+	im := Image{Position: "top-left", Opacity: 1}
+	got := im.FilterAs("pip", "2:v", "inset")
+	want := "[2:v]format=rgba[inset];[pip][inset]overlay=x=0:y=0"
+	if got != want {
+		t.Errorf("FilterAs = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Package overlay builds ffmpeg filters that draw on top of the video.
+package overlay
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// margin is the distance in pixels between text and the frame edge.
+const margin = 16
+
+// positions maps a position name to horizontal and vertical alignment:
+// -1 is left/top, 0 centered, 1 right/bottom.
+var positions = map[string][2]int{
+	"top-left":     {-1, -1},
+	"top":          {0, -1},
+	"top-right":    {1, -1},
+	"center":       {0, 0},
+	"bottom-left":  {-1, 1},
+	"bottom":       {0, 1},
+	"bottom-right": {1, 1},
+}
+
+// place returns x/y expressions for an item of size (w, h) on a frame of
+// size (frameW, frameH), where all four are ffmpeg expression variables.
+func place(pos string, frameW, w, frameH, h string, margin int) (x, y string) {
+	align := func(a int, frame, size string) string {
+		switch a {
+		case -1:
+			return fmt.Sprintf("%d", margin)
+		case 1:
+			return fmt.Sprintf("%s-%s-%d", frame, size, margin)
+		}
+		return fmt.Sprintf("(%s-%s)/2", frame, size)
+	}
+	a := positions[pos]
+	return align(a[0], frameW, w), align(a[1], frameH, h)
+}
+
+// Positions returns the supported position names, sorted.
+func Positions() []string {
+	names := make([]string, 0, len(positions))
+	for name := range positions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckPosition reports whether name is a supported position.
+func CheckPosition(name string) error {
+	if _, ok := positions[name]; !ok {
+		return fmt.Errorf("unknown position %q (use %s)", name, strings.Join(Positions(), ", "))
+	}
+	return nil
+}
+
+// Style is how text is drawn. FontFile is required: drawtext without it
+// depends on fontconfig, which many ffmpeg builds lack.
+type Style struct {
+	FontFile string
+	Size     int
+	Color    string
+	Box      bool // draw a translucent box behind the text
+}
+
+// TextFile returns a drawtext filter showing the contents of textFile
+// verbatim. Reading text from a file avoids escaping arbitrary captions.
+func TextFile(s Style, pos, textFile string) string {
+	return drawtext(s, pos, "textfile="+Escape(textFile), "expansion=none")
+}
+
+// Timecode returns a drawtext filter showing the running output time as
+// HH:MM:SS.mmm.
+func Timecode(s Style, pos string) string {
+	return drawtext(s, pos, "text="+Escape("%{pts:hms}"))
+}
+
+func drawtext(s Style, pos string, opts ...string) string {
+	x, y := place(pos, "w", "text_w", "h", "text_h", margin)
+	args := []string{"fontfile=" + Escape(s.FontFile)}
+	args = append(args, opts...)
+	args = append(args,
+		fmt.Sprintf("fontsize=%d", s.Size),
+		"fontcolor="+Escape(s.Color),
+		"x="+x,
+		"y="+y,
+	)
+	if s.Box {
+		args = append(args, "box=1", "boxcolor=black@0.5", fmt.Sprintf("boxborderw=%d", max(s.Size/4, 1)))
+	}
+	return "drawtext=" + strings.Join(args, ":")
+}
+
+// Escape quotes a value for use as a filter option inside a -vf/-filter_complex
+// string. ffmpeg unescapes twice: once for the filter's options and once for
+// the filtergraph.
+func Escape(v string) string {
+	opt := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(v)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(opt)
+}
+
+// fontCandidates are common TrueType fonts, checked in order by FindFont.
+var fontCandidates = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
+	"/usr/share/fonts/liberation/LiberationSans-Regular.ttf",
+	"/usr/local/share/fonts/dejavu/DejaVuSans.ttf",
+	"/System/Library/Fonts/Supplemental/Arial.ttf",
+	"/Library/Fonts/Arial.ttf",
+	`C:\Windows\Fonts\arial.ttf`,
+}
+
+// bundledFont is DejaVu Sans (see fonts/LICENSE), compiled in so that text
+// overlays work on hosts without any fonts.
+//
+//go:embed fonts/DejaVuSans.ttf
+var bundledFont []byte
+
+// BundledFont writes the font compiled into gif2vid to dir and returns its
+// path, for when FindFont finds none.
+func BundledFont(dir string) (string, error) {
+	p := filepath.Join(dir, "DejaVuSans.ttf")
+	if err := os.WriteFile(p, bundledFont, 0o644); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// FindFont returns a font for text overlays: the first .ttf in a fonts
+// directory next to the executable (or in ../share/gif2vid/fonts), else a
+// common system font. It returns "" if none is found; BundledFont always
+// provides one.
+func FindFont() string {
+	if exe, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exe)
+		for _, d := range []string{filepath.Join(dir, "fonts"), filepath.Join(dir, "..", "share", "gif2vid", "fonts")} {
+			if m, _ := filepath.Glob(filepath.Join(d, "*.ttf")); len(m) > 0 {
+				sort.Strings(m)
+				return m[0]
+			}
+		}
+	}
+	for _, p := range fontCandidates {
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/tmp/work/caption_0001.txt", "/tmp/work/caption_0001.txt"},
+		{`C:\Fonts\a.ttf`, `C\\:\\\\Fonts\\\\a.ttf`},
+		{"%{pts:hms}", `%{pts\\:hms}`},
+		{"it's [1],2;", `it\\\'s \[1\]\,2\;`},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.want {
+			t.Errorf("Escape(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTextFile(t *testing.T) {
+	s := Style{FontFile: "/f.ttf", Size: 24, Color: "white"}
+	got := TextFile(s, "bottom", "/w/c.txt")
+	want := "drawtext=fontfile=/f.ttf:textfile=/w/c.txt:expansion=none:fontsize=24:fontcolor=white:x=(w-text_w)/2:y=h-text_h-16"
+	if got != want {
+		t.Errorf("TextFile = %q; want %q", got, want)
+	}
+
+	s.Box = true
+	got = Timecode(s, "top-right")
+	want = `drawtext=fontfile=/f.ttf:text=%{pts\\:hms}:fontsize=24:fontcolor=white:x=w-text_w-16:y=16:box=1:boxcolor=black@0.5:boxborderw=6`
+	if got != want {
+		t.Errorf("Timecode = %q; want %q", got, want)
+	}
+}
+
+func TestCheckPosition(t *testing.T) {
+	if err := CheckPosition("top-left"); err != nil {
+		t.Error(err)
+	}
+	if err := CheckPosition("middle"); err == nil {
+		t.Error("expected error for unknown position")
+	}
+}
+
+func TestBundledFont(t *testing.T) {
+	p, err := BundledFont(t.TempDir())
+	if err != nil {
+		t.Fatalf("BundledFont failed: %v", err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// TrueType files start with the sfnt version 0x00010000.
+	if len(b) < 4 || string(b[:4]) != "\x00\x01\x00\x00" || filepath.Ext(p) != ".ttf" {
+		t.Errorf("%s is not a TrueType font", p)
+	}
+}
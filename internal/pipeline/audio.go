@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/config"
+)
+
+// silence is an input producing endless silent stereo audio.
+var silence = []string{"-f", "lavfi", "-i", "anullsrc=r=48000:cl=stereo"}
+
+// clipAudioFilter normalizes a segment's sound and pads it with silence, so
+// every segment carries identical audio parameters for the concat demuxer.
+const clipAudioFilter = "aresample=48000,aformat=channel_layouts=stereo,apad"
+
+// segmentAudio returns the extra inputs and the output options for the audio
+// of a segment whose video is input 0. Segments are silent (-an) unless
+// --audio-per-clip is set; then each gets a track, from the input when it has
+// sound and silence otherwise, cut to the video length. Sound follows the
+// input's speed and reverse effects; boomerangs get silence.
+func segmentAudio(cfg *config.Config, in Input) (inputs, outputs []string) {
+	if !cfg.AudioPerClip {
+		return nil, []string{"-an"}
+	}
+	src, filter := "0:a:0", clipAudioFilter
+	if !in.Audio || in.Boomerang {
+		inputs = silence
+		src = "1:a"
+	} else if effects := audioEffects(in); effects != "" {
+		filter = effects + "," + filter
+	}
+	// Intermediate segments are re-encoded by the final mux, so keep them
+	// at a high bitrate.
+	return inputs, []string{"-map", "0:v:0", "-map", src, "-af", filter, "-c:a", "aac", "-b:a", "320k", "-shortest"}
+}
+
+// backgroundFilter returns the filter chain fitting the background audio to
+// a video of the given duration: resampled, volume adjusted, padded with
+// silence or trimmed, then faded.
+func backgroundFilter(cfg *config.Config, duration float64) string {
+	f := []string{"aresample=48000", "aformat=channel_layouts=stereo"}
+	if cfg.AudioVolume != 1 {
+		f = append(f, "volume="+formatSeconds(cfg.AudioVolume))
+	}
+	f = append(f, "apad", "atrim=end="+formatSeconds(duration))
+	if cfg.AudioFadeIn > 0 {
+		f = append(f, "afade=t=in:st=0:d="+formatSeconds(cfg.AudioFadeIn))
+	}
+	if cfg.AudioFadeOut > 0 {
+		start := max(duration-cfg.AudioFadeOut, 0)
+		f = append(f, "afade=t=out:st="+formatSeconds(start)+":d="+formatSeconds(cfg.AudioFadeOut))
+	}
+	return strings.Join(f, ",")
+}
+
+// audioEncodeArgs returns the audio encoder options for the output.
+func audioEncodeArgs(cfg *config.Config) []string {
+	return []string{"-c:a", config.AudioCodecs[cfg.AudioCodec], "-b:a", cfg.AudioBitrate}
+}
+
+func formatSeconds(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', -1, 64)
+}
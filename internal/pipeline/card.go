@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/overlay"
+)
+
+// Card is a generated title or end card.
+type Card struct {
+	Name  string // "intro" or "outro"
+	Text  string
+	Image string // background image; empty for a solid color
+}
+
+// cards returns the title and end cards cfg asks for; either may be nil.
+func cards(cfg *config.Config) (intro, outro *Card) {
+	if cfg.Intro != "" || cfg.IntroImage != "" {
+		intro = &Card{Name: "intro", Text: cfg.Intro, Image: cfg.IntroImage}
+	}
+	if cfg.Outro != "" || cfg.OutroImage != "" {
+		outro = &Card{Name: "outro", Text: cfg.Outro, Image: cfg.OutroImage}
+	}
+	return intro, outro
+}
+
+// renderCard encodes card into tmpDir at the plan's canvas size and frame
+// rate. It goes through BuildFilter and encodeArgs like every input segment,
+// so the concat demuxer can join them without pixel format mismatches.
+func renderCard(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, plan *Plan, card *Card, style overlay.Style, tmpDir string) (*Segment, error) {
+	start := time.Now()
+	seg := &Segment{
+		Input:   Input{Path: card.Image, Width: plan.Width, Height: plan.Height, Caption: card.Text},
+		Path:    filepath.Join(tmpDir, card.Name+".mp4"),
+		Decoder: DecoderFFmpeg,
+	}
+	dur := strconv.FormatFloat(cfg.CardDuration, 'f', -1, 64)
+	args := []string{"-y"}
+	if card.Image != "" {
+		args = append(args, "-loop", "1", "-framerate", strconv.Itoa(cfg.FPS), "-t", dur, "-i", card.Image)
+	} else {
+		src := fmt.Sprintf("color=c=%s:s=%dx%d:r=%d:d=%s", overlay.Escape(cfg.CardColor), plan.Width, plan.Height, cfg.FPS, dur)
+		args = append(args, "-f", "lavfi", "-i", src)
+	}
+
+	audioIn, audioOut := segmentAudio(cfg, Input{})
+	args = append(args, audioIn...)
+
+	var overlays []string
+	if card.Text != "" {
+		textFile := filepath.Join(tmpDir, card.Name+".txt")
+		if err := os.WriteFile(textFile, []byte(card.Text), 0o644); err != nil {
+			return nil, err
+		}
+		style.Size = cfg.CardFontSize
+		overlays = append(overlays, overlay.TextFile(style, "center", textFile))
+	}
+	// Pad image backgrounds with the card color rather than --bg.
+	cardCfg := *cfg
+	cardCfg.BG = cfg.CardColor
+	args = append(args, "-vf", BuildFilter(&cardCfg, plan.Width, plan.Height, overlays...))
+	args = append(args, audioOut...)
+	args = append(args, encodeArgs(cfg)...)
+	args = append(args, "-t", dur, seg.Path)
+	if _, stderr, err := r.Run(ctx, "ffmpeg", args); err != nil {
+		return nil, fmt.Errorf("ffmpeg %s card failed:\ncmd: %s\n%s", card.Name, ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+	}
+	seg.EncodeTime = time.Since(start)
+	return seg, nil
+}
@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/crit/gif2vid/internal/concat"
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/media"
+	"github.com/crit/gif2vid/internal/overlay"
+)
+
+// RunCompare pairs cfg.Inputs with cfg.CompareInputs by filename and plays
+// each pair stacked side by side (or top and bottom), one pair after another.
+// Both sides are encoded to the same canvas like Run's segments; the shorter
+// clip of a pair holds its last frame until the longer one ends. Files with
+// no counterpart are skipped and listed in Result.Unmatched.
+func RunCompare(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer) (res *Result, err error) {
+	a, b, unmatched := pairByName(cfg.Inputs, cfg.CompareInputs)
+	if len(a) == 0 {
+		return &Result{Unmatched: unmatched}, fmt.Errorf("no files with matching names in %s and %s", cfg.InputDir, cfg.CompareDir)
+	}
+	both := *cfg
+	both.Inputs = append(a, b...)
+	res, tmpDir, style, err := encodeInputs(ctx, r, &both, obs, nil)
+	defer discard(cfg, tmpDir, &err)
+	if res != nil {
+		res.Unmatched = unmatched
+	}
+	if err != nil {
+		return res, err
+	}
+	for i := range res.Segments {
+		seg := &res.Segments[i]
+		if seg.Duration, err = media.Duration(ctx, r, seg.Path); err != nil {
+			return res, err
+		}
+	}
+
+	var labels [2]string
+	if cfg.Labels {
+		for i, text := range []string{
+			cmp.Or(cfg.LabelA, filepath.Base(cfg.InputDir)),
+			cmp.Or(cfg.LabelB, filepath.Base(cfg.CompareDir)),
+		} {
+			path := filepath.Join(tmpDir, "label_"+strconv.Itoa(i)+".txt")
+			if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+				return res, err
+			}
+			labels[i] = overlay.TextFile(style, "top", path)
+		}
+	}
+
+	n := len(a)
+	var pairs []string
+	offset := 0.0
+	for i := range n {
+		left, right := &res.Segments[i], &res.Segments[n+i]
+		d := max(left.Duration, right.Duration)
+		left.Start, right.Start = offset, offset
+		offset += d
+		out := filepath.Join(tmpDir, fmt.Sprintf("pair_%04d.mp4", i))
+		args := []string{"-i", left.Path, "-i", right.Path,
+			"-filter_complex", stackFilter(cfg.Stack, labels) + "[v]",
+			"-map", "[v]",
+		}
+		args = append(args, encodeArgs(cfg)...)
+		args = append(args, "-an", "-t", formatSeconds(d), "-pix_fmt", "yuv420p", out)
+		if _, stderr, err := r.Run(ctx, "ffmpeg", args); err != nil {
+			return res, fmt.Errorf("ffmpeg stack failed for %s:\ncmd: %s\n%s", filepath.Base(left.Input.Path), ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+		}
+		pairs = append(pairs, out)
+	}
+	res.Duration = offset
+	if cfg.MaxDuration > 0 {
+		res.Duration = min(res.Duration, cfg.MaxDuration)
+	}
+
+	// The pairs share encode settings, so they join without re-encoding.
+	concatPath := filepath.Join(tmpDir, "concat.txt")
+	if err := concat.WriteConcatFile(concatPath, pairs); err != nil {
+		return res, err
+	}
+	outTmp := filepath.Join(tmpDir, "out.tmp.mp4")
+	emit(obs, Event{Kind: EventConcatStarted, Total: len(res.Segments)})
+	args := []string{"-f", "concat", "-safe", "0", "-i", concatPath, "-c", "copy"}
+	if cfg.MaxDuration > 0 {
+		args = append(args, "-t", formatSeconds(cfg.MaxDuration))
+	}
+	args = append(args, "-movflags", "+faststart", outTmp)
+	if _, stderr, err := r.Run(ctx, "ffmpeg", args); err != nil {
+		return res, fmt.Errorf("ffmpeg concat failed:\ncmd: %s\n%s", ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+	}
+	if err := publish(cfg, res, outTmp); err != nil {
+		return res, err
+	}
+	finish(cfg, tmpDir, len(res.Segments), obs)
+	return res, nil
+}
+
+// pairByName matches the files in a and b with the same base name, in a's
+// order. Unmatched lists the rest: a's, then b's.
+func pairByName(a, b []string) (pairedA, pairedB, unmatched []string) {
+	byName := map[string]string{}
+	for _, p := range b {
+		byName[filepath.Base(p)] = p
+	}
+	used := map[string]bool{}
+	for _, p := range a {
+		if q, ok := byName[filepath.Base(p)]; ok && !used[q] {
+			used[q] = true
+			pairedA = append(pairedA, p)
+			pairedB = append(pairedB, q)
+		} else {
+			unmatched = append(unmatched, p)
+		}
+	}
+	for _, q := range b {
+		if !used[q] {
+			unmatched = append(unmatched, q)
+		}
+	}
+	return pairedA, pairedB, unmatched
+}
+
+// stackFilter places inputs 0 and 1 next to each other ("horizontal") or
+// one above the other, each padded with its last frame (tpad) so the shorter
+// one holds still, and drawn with its label when there is one.
+func stackFilter(stack string, labels [2]string) string {
+	stacker := "hstack"
+	if stack == "vertical" {
+		stacker = "vstack"
+	}
+	graph := ""
+	for i, label := range labels {
+		graph += "[" + strconv.Itoa(i) + ":v]tpad=stop=-1:stop_mode=clone"
+		if label != "" {
+			graph += "," + label
+		}
+		graph += "[s" + strconv.Itoa(i) + "];"
+	}
+	return graph + "[s0][s1]" + stacker + "=inputs=2"
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/inputs"
+	"github.com/crit/gif2vid/internal/media"
+)
+
+// effectsFilter returns the trim and playback effects for an input as a
+// filter chain to run before BuildFilter's, or "" for none. Trimming comes
+// first so the effects only see the kept range. Boomerang uses labeled links,
+// which a single -vf graph allows.
+func effectsFilter(in Input) string {
+	var f []string
+	if trim := trimOptions(in.Start, in.End, "_frame", 0); trim != "" {
+		f = append(f, "trim="+trim, "setpts=PTS-STARTPTS")
+	}
+	if in.Reverse {
+		f = append(f, "reverse")
+	}
+	if in.Boomerang {
+		f = append(f, "split[fwd][back];[back]reverse[rev];[fwd][rev]concat=n=2:v=1:a=0")
+	}
+	if in.Speed > 0 && in.Speed != 1 {
+		f = append(f, "setpts=(PTS-STARTPTS)/"+formatSeconds(in.Speed))
+	}
+	return strings.Join(f, ",")
+}
+
+// audioEffects returns the audio filters matching effectsFilter, or "" for
+// none. atempo accepts at most 2x per instance, so faster speeds chain two.
+func audioEffects(in Input) string {
+	var f []string
+	if trim := trimOptions(in.Start, in.End, "", in.Rate); trim != "" {
+		f = append(f, "atrim="+trim, "asetpts=PTS-STARTPTS")
+	}
+	if in.Reverse {
+		f = append(f, "areverse")
+	}
+	switch {
+	case in.Speed > 2:
+		f = append(f, "atempo=2", "atempo="+formatSeconds(in.Speed/2))
+	case in.Speed > 0 && in.Speed != 1:
+		f = append(f, "atempo="+formatSeconds(in.Speed))
+	}
+	return strings.Join(f, ",")
+}
+
+// trimOptions returns the start/end options of a trim or atrim filter.
+// Frame marks use the start<frameSuffix> options when frameSuffix is set,
+// and are converted to seconds at rate otherwise (atrim has no frame options).
+func trimOptions(start, end inputs.Mark, frameSuffix string, rate float64) string {
+	var opts []string
+	for _, m := range []struct {
+		name string
+		mark inputs.Mark
+	}{{"start", start}, {"end", end}} {
+		switch {
+		case m.mark.IsZero():
+		case m.mark.Frames && frameSuffix != "":
+			opts = append(opts, fmt.Sprintf("%s%s=%d", m.name, frameSuffix, m.mark.Frame))
+		default:
+			opts = append(opts, m.name+"="+formatSeconds(m.mark.Sec(rate)))
+		}
+	}
+	return strings.Join(opts, ":")
+}
+
+// checkTrim fails early when in's trim range falls outside its probed
+// length, and sets in.Rate when a mark counts frames.
+func checkTrim(ctx context.Context, r ffmpeg.Runner, in *Input) error {
+	dur, err := media.Duration(ctx, r, in.Path)
+	if err != nil {
+		return err
+	}
+	length := formatSeconds(dur) + "s"
+	frames := 0
+	if in.Start.Frames || in.End.Frames {
+		if frames, err = media.Frames(ctx, r, in.Path); err != nil {
+			return err
+		}
+		if dur > 0 {
+			in.Rate = float64(frames) / dur
+		}
+		length += fmt.Sprintf(", %d frames", frames)
+	}
+	name := filepath.Base(in.Path)
+	start, end := in.Start, in.End
+	switch {
+	case start.Frames && start.Frame >= frames, !start.Frames && start.Seconds >= dur:
+		return fmt.Errorf("%s: trim start %s is past the end of the clip (%s)", name, start, length)
+	case end.Frames && end.Frame > frames, !end.Frames && end.Seconds > dur:
+		return fmt.Errorf("%s: trim end %s is past the end of the clip (%s)", name, end, length)
+	case !end.IsZero() && end.Sec(in.Rate) <= start.Sec(in.Rate):
+		return fmt.Errorf("%s: trim end %s must be after the start %s", name, end, cmp.Or(start.String(), "0s"))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+// EventKind identifies a pipeline progress step.
+type EventKind string
+
+const (
+	EventProbed         EventKind = "probed"          // Input, Width, Height
+	EventSegmentStarted EventKind = "segment_started" // Index, Input
+	EventSegmentDone    EventKind = "segment_done"    // Index, Input, Path
+	EventFallback       EventKind = "fallback"        // Index, Input; ffmpeg failed and ImageMagick is used
+	EventSegmentFailed  EventKind = "segment_failed"  // Index, Input, Err
+	EventConcatStarted  EventKind = "concat_started"
+	EventDone           EventKind = "done"      // Path is the output
+	EventTempKept       EventKind = "temp_kept" // Path is the workspace
+)
+
+// Event describes one progress step. Index is the input's position and
+// Total the number of inputs.
+type Event struct {
+	Kind   EventKind
+	Index  int
+	Total  int
+	Input  string
+	Path   string
+	Width  int
+	Height int
+	Err    error
+}
+
+// Observer receives events while the pipeline runs. Segment events arrive
+// from worker goroutines, so implementations must be safe for concurrent use.
+type Observer interface {
+	Event(Event)
+}
+
+// ObserverFunc adapts a function to Observer.
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) Event(e Event) { f(e) }
+
+func emit(obs Observer, e Event) {
+	if obs != nil {
+		obs.Event(e)
+	}
+}
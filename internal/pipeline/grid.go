@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/media"
+)
+
+// RunGrid tiles the inputs on one canvas and plays them at the same time.
+// Each input is encoded to a cell-sized segment the way Run encodes them,
+// then the segments are looped to cfg.GridDuration (or the longest segment)
+// and joined with xstack. Cards, audio, chapters, subtitles and the image
+// watermark only apply to sequential builds.
+func RunGrid(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer) (res *Result, err error) {
+	if len(cfg.Inputs) < 2 {
+		return nil, errors.New("a grid needs at least two inputs")
+	}
+	res, tmpDir, _, err := encodeInputs(ctx, r, cfg, obs, nil)
+	defer discard(cfg, tmpDir, &err)
+	if err != nil {
+		return res, err
+	}
+	plan := res.Plan
+	total := len(plan.Inputs)
+	longest := 0.0
+	for i := range res.Segments {
+		seg := &res.Segments[i]
+		if seg.Duration, err = media.Duration(ctx, r, seg.Path); err != nil {
+			return res, err
+		}
+		longest = max(longest, seg.Duration)
+	}
+	res.Duration = longest
+	if cfg.GridDuration > 0 {
+		res.Duration = cfg.GridDuration
+	}
+	if cfg.MaxDuration > 0 {
+		res.Duration = min(res.Duration, cfg.MaxDuration)
+	}
+
+	outTmp := filepath.Join(tmpDir, "out.tmp.mp4")
+	emit(obs, Event{Kind: EventConcatStarted, Total: total})
+	var args []string
+	for _, seg := range res.Segments {
+		args = append(args, "-stream_loop", "-1", "-i", seg.Path)
+	}
+	cols := gridCols(total, cfg.Cols)
+	args = append(args,
+		"-filter_complex", xstackFilter(total, cols, plan.Width, plan.Height, cfg.Gutter, cfg.BG)+"[v]",
+		"-map", "[v]",
+	)
+	args = append(args, encodeArgs(cfg)...)
+	args = append(args,
+		"-an",
+		"-t", formatSeconds(res.Duration),
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outTmp,
+	)
+	_, stderr, err := r.Run(ctx, "ffmpeg", args)
+	if err != nil {
+		return res, fmt.Errorf("ffmpeg grid failed:\ncmd: %s\n%s", ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+	}
+	if err := publish(cfg, res, outTmp); err != nil {
+		return res, err
+	}
+	finish(cfg, tmpDir, total, obs)
+	return res, nil
+}
+
+// gridCols returns cols, or for 0 the fewest columns that keep the grid no
+// taller than it is wide (3 for 7-9 inputs).
+func gridCols(n, cols int) int {
+	if cols > 0 {
+		return min(cols, n)
+	}
+	return int(math.Ceil(math.Sqrt(float64(n))))
+}
+
+// xstackFilter lays out n inputs of w×h in rows of cols, gutter pixels apart.
+// Cells left over in the last row, and the gutters, are filled with bg.
+func xstackFilter(n, cols, w, h, gutter int, bg string) string {
+	var in, layout []string
+	for i := range n {
+		in = append(in, "["+strconv.Itoa(i)+":v]")
+		layout = append(layout, fmt.Sprintf("%d_%d", i%cols*(w+gutter), i/cols*(h+gutter)))
+	}
+	return fmt.Sprintf("%sxstack=inputs=%d:layout=%s:fill=%s", strings.Join(in, ""), n, strings.Join(layout, "|"), bg)
+}
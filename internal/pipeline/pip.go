@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/overlay"
+)
+
+// RunPIP builds cfg.Inputs the way Run does and plays cfg.InsetInputs, built
+// as a plain sequence at their own size, as a scaled inset in one corner. The
+// Result describes the main sequence.
+func RunPIP(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer) (*Result, error) {
+	if len(cfg.InsetInputs) == 0 {
+		return nil, errors.New("no inset inputs")
+	}
+	if cfg.Split() {
+		return nil, config.ErrSplitPIP
+	}
+	tmpDir, err := NewWorkspace(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.KeepTemp {
+		defer os.RemoveAll(tmpDir)
+	}
+
+	// The inset is built first so the main sequence's final mux can draw it
+	// instead of encoding the main video a second time.
+	inset := insetConfig(cfg, tmpDir)
+	if _, err := Run(ctx, r, inset, nil); err != nil {
+		return nil, fmt.Errorf("inset: %w", err)
+	}
+	return run(ctx, r, cfg, obs, nil, inset.Output)
+}
+
+// insetImage returns how the inset sequence is drawn over a plan's canvas.
+func insetImage(cfg *config.Config, plan *Plan) overlay.Image {
+	im := overlay.Image{
+		Position:    cfg.InsetPos,
+		Margin:      cfg.InsetMargin,
+		Opacity:     1,
+		Border:      cfg.InsetBorder,
+		BorderColor: cfg.InsetBorderColor,
+	}
+	if cfg.InsetScale > 0 {
+		im.Width = even(int(float64(plan.Width)*cfg.InsetScale + 0.5))
+	}
+	return im
+}
+
+// insetConfig returns the settings for the inset sequence: cfg's encoding
+// and per-clip settings, at the inset files' own size, without the extras
+// that belong to the main video.
+func insetConfig(cfg *config.Config, tmpDir string) *config.Config {
+	c := *cfg
+	c.Inputs = cfg.InsetInputs
+	c.Output = filepath.Join(tmpDir, "inset.mp4")
+	c.TmpDir = tmpDir
+	c.Overwrite = true // an intermediate file, not the user's output
+	c.Width, c.Height = 0, 0
+	c.MaxDuration = 0
+	c.SplitMaxDuration, c.SplitMaxSize, c.SplitEvery = 0, 0, 0
+	c.Manifest = ""
+	c.Chapters, c.Subtitles, c.EmbedSubs = false, "", false
+	c.Captions, c.WatermarkText, c.Timecode = false, "", false
+	c.Watermark = ""
+	c.Intro, c.IntroImage, c.Outro, c.OutroImage = "", "", "", ""
+	c.Audio, c.AudioPerClip, c.SilentAudio = "", false, false
+	return &c
+}
@@ -1,16 +1,27 @@
 package pipeline
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/crit/gif2vid/internal/concat"
 	"github.com/crit/gif2vid/internal/config"
 	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/inputs"
 	"github.com/crit/gif2vid/internal/media"
+	"github.com/crit/gif2vid/internal/overlay"
+	"github.com/crit/gif2vid/internal/subtitles"
+	"github.com/crit/gif2vid/internal/util"
 )
 
 // even rounds up to the nearest even number.
@@ -21,20 +32,90 @@ func even(x int) int {
 	return x
 }
 
-// BuildFilter builds the ffmpeg -vf filter string.
-func BuildFilter(cfg *config.Config, targetW, targetH int) string {
-	return fmt.Sprintf("fps=%d,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=%s,format=yuv420p",
+// BuildFilter builds the ffmpeg -vf filter string. Overlays (such as
+// drawtext filters) are drawn on the padded canvas.
+func BuildFilter(cfg *config.Config, targetW, targetH int, overlays ...string) string {
+	f := fmt.Sprintf("fps=%d,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=%s",
 		cfg.FPS, targetW, targetH, targetW, targetH, cfg.BG)
+	for _, o := range overlays {
+		f += "," + o
+	}
+	return f + ",format=yuv420p"
+}
+
+// encodeArgs returns the video encoder options shared by every encode.
+func encodeArgs(cfg *config.Config) []string {
+	codec := cfg.Codec
+	if codec == "" {
+		codec = "libx264"
+	}
+	args := []string{"-c:v", codec, "-preset", cfg.Preset}
+	if cfg.Bitrate != "" {
+		args = append(args, "-b:v", cfg.Bitrate)
+	} else {
+		args = append(args, "-crf", fmt.Sprintf("%d", cfg.CRF))
+	}
+	if codec == "libx265" {
+		// hvc1 tagging lets Apple players open HEVC MP4s
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
+
+// Input is a probed source file.
+type Input struct {
+	Path    string
+	Width   int
+	Height  int
+	Caption string // from the manifest or a sidecar .txt; empty if none
+	Audio   bool   // has sound; only probed with --audio-per-clip
+
+	// Still images (PNG/JPEG, but not APNG) are shown for Duration seconds
+	// with an optional Motion from config.StillMotions.
+	Still    bool
+	Duration float64
+	Motion   string
+
+	// Playback effects for animated inputs, from the manifest or flags.
+	Speed     float64
+	Reverse   bool
+	Boomerang bool
+
+	// Trim range; a zero End keeps the rest of the input. Rate is the
+	// average frame rate, set when a mark is a frame number.
+	Start, End inputs.Mark
+	Rate       float64
+}
+
+// Title is the caption, or the filename when there is none.
+func (in Input) Title() string {
+	if in.Caption != "" {
+		return in.Caption
+	}
+	return filepath.Base(in.Path)
 }
 
-// Run executes the full pipeline.
-func Run(ctx context.Context, r ffmpeg.Runner, cfg *config.Config) error {
-	// Probe inputs and compute target canvas
+// Plan is the probed inputs and the canvas every segment is fitted to.
+type Plan struct {
+	Width     int
+	Height    int
+	Inputs    []Input
+	Watermark *Input // probed watermark image; nil if none
+	Audio     string // absolute path of the background audio; empty if none
+}
+
+// NewPlan probes every input and computes the target canvas.
+func NewPlan(ctx context.Context, r ffmpeg.Runner, cfg *config.Config) (*Plan, error) {
+	manifest, err := loadManifest(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{Inputs: make([]Input, 0, len(cfg.Inputs))}
 	maxW, maxH := 0, 0
 	for _, in := range cfg.Inputs {
 		w, h, err := media.Probe(ctx, r, cfg, in)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if w > maxW {
 			maxW = w
@@ -42,132 +123,749 @@ func Run(ctx context.Context, r ffmpeg.Runner, cfg *config.Config) error {
 		if h > maxH {
 			maxH = h
 		}
+		clip, _ := manifest.Lookup(in)
+		caption := clip.Caption
+		if caption == "" {
+			caption = inputs.SidecarCaption(in)
+		}
+		input := Input{Path: in, Width: w, Height: h, Caption: caption}
+		if cfg.AudioPerClip {
+			if input.Audio, err = media.HasAudio(ctx, r, in); err != nil {
+				return nil, err
+			}
+		}
+		if inputs.IsImage(in) {
+			codec, err := media.VideoCodec(ctx, r, in)
+			if err != nil {
+				return nil, err
+			}
+			if input.Still = codec != "apng"; input.Still {
+				input.Duration = cmp.Or(clip.Duration, cfg.StillDur)
+				input.Motion = cmp.Or(clip.Motion, cfg.StillMotion)
+			}
+		}
+		if !input.Still {
+			input.Speed = cmp.Or(clip.Speed, cfg.Speed, 1)
+			input.Reverse = cfg.Reverse
+			if clip.Reverse != nil {
+				input.Reverse = *clip.Reverse
+			}
+			input.Boomerang = cfg.Boomerang
+			if clip.Boomerang != nil {
+				input.Boomerang = *clip.Boomerang
+			}
+			input.Start = cmp.Or(clip.Start, cfg.TrimStart)
+			input.End = cmp.Or(clip.End, cfg.TrimEnd)
+			if !input.Start.IsZero() || !input.End.IsZero() {
+				if err := checkTrim(ctx, r, &input); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.Inputs = append(p.Inputs, input)
+	}
+	p.Width = even(maxW)
+	p.Height = even(maxH)
+	if cfg.Width > 0 {
+		p.Width = even(cfg.Width)
+	}
+	if cfg.Height > 0 {
+		p.Height = even(cfg.Height)
+	}
+	if p.Width == 0 || p.Height == 0 {
+		return nil, fmt.Errorf("failed to determine target dimensions")
+	}
+	if cfg.Watermark != "" {
+		if p.Watermark, err = probeImage(ctx, r, cfg, "watermark", cfg.Watermark); err != nil {
+			return nil, err
+		}
 	}
-	maxW = even(maxW)
-	maxH = even(maxH)
-	if maxW == 0 || maxH == 0 {
-		return fmt.Errorf("failed to determine target dimensions")
+	if cfg.Audio != "" {
+		if p.Audio, err = probeAudio(ctx, r, cfg.Audio); err != nil {
+			return nil, err
+		}
 	}
+	for _, card := range [][2]string{{"intro image", cfg.IntroImage}, {"outro image", cfg.OutroImage}} {
+		if card[1] != "" {
+			if _, err := probeImage(ctx, r, cfg, card[0], card[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
 
-	// Temp workspace
-	tmpDir, err := filepath.Abs(cfg.TmpDir)
+// probeAudio checks that the background audio exists and has sound, and
+// returns its absolute path.
+func probeAudio(ctx context.Context, r ffmpeg.Runner, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return "", fmt.Errorf("audio not found: %s", path)
+	}
+	ok, err := media.HasAudio(ctx, r, abs)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("audio %s has no audio stream", path)
+	}
+	return abs, nil
+}
+
+// probeImage checks that an auxiliary image such as the watermark exists
+// and can be decoded, the same way inputs are probed.
+func probeImage(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, what, path string) (*Input, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("%s not found: %s", what, path)
+	}
+	w, h, err := media.Probe(ctx, r, cfg, abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s: %w", what, path, err)
+	}
+	return &Input{Path: abs, Width: w, Height: h}, nil
+}
+
+// loadManifest reads cfg.Manifest, if set, and checks that every clip it
+// lists is one of the inputs so typos fail early.
+func loadManifest(cfg *config.Config) (*inputs.Manifest, error) {
+	if cfg.Manifest == "" {
+		return nil, nil
+	}
+	m, err := inputs.LoadManifest(cfg.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range m.Clips {
+		found := false
+		for _, in := range cfg.Inputs {
+			if got, ok := m.Lookup(in); ok && got.File == c.File {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("manifest %s: %s matches no input", cfg.Manifest, c.File)
+		}
+		if c.Motion != "" && !slices.Contains(config.StillMotions, c.Motion) {
+			return nil, fmt.Errorf("manifest %s: %s has unknown motion %q", cfg.Manifest, c.File, c.Motion)
+		}
+	}
+	return m, nil
+}
+
+// WorkspaceBase returns the directory that temp workspaces are created in:
+// cfg.TmpDir, or the system temp directory.
+func WorkspaceBase(cfg *config.Config) string {
 	if cfg.TmpDir == "" {
-		// pick a default temp under OS temp; we won't add util.MkTempWorkspace to avoid extra deps here
-		tmpDir, err = filepath.Abs(filepath.Join(os.TempDir(), "gif2vid-work"))
+		return os.TempDir()
 	}
+	return cfg.TmpDir
+}
+
+// NewWorkspace creates a temp workspace of its own in WorkspaceBase(cfg) and
+// returns its absolute path. Every run gets one, so removing it never touches
+// the files of another run.
+func NewWorkspace(cfg *config.Config) (string, error) {
+	dir, err := util.MkTempWorkspace(cfg.TmpDir)
 	if err != nil {
-		return err
+		return "", err
+	}
+	return filepath.Abs(dir)
+}
+
+// Decoders that can produce a segment.
+const (
+	DecoderFFmpeg = "ffmpeg"
+	DecoderMagick = "imagemagick"
+)
+
+// Segment is the encoded clip for one input.
+type Segment struct {
+	Input      Input
+	Path       string
+	Decoder    string  // DecoderFFmpeg, or DecoderMagick after a fallback
+	Duration   float64 // seconds, probed from the encoded segment
+	Start      float64 // offset of the segment in the output, in seconds
+	Offset     float64 // seconds into Path where the segment begins; 0 unless cut
+	Cut        bool    // ends before Path does, as a piece of a clip split across parts
+	EncodeTime time.Duration
+	Cached     bool // reused from a Shared cache instead of encoded
+	Err        error
+}
+
+// Result describes a run. Run returns a partial Result alongside most errors.
+type Result struct {
+	Plan       *Plan
+	Segments   []Segment // one per input, in input order
+	Intro      *Segment  // title card; nil if none
+	Outro      *Segment  // end card; nil if none
+	Duration   float64   // output length in seconds
+	Output     string
+	OutputSize int64
+	Unmatched  []string // compare inputs with no counterpart of the same name
+	Outputs    []string // the parts of a split output, in order; nil if not split
+	Workspace  string   // temp directory of the run, removed at the end unless KeepTemp
+}
+
+// Run executes the full pipeline, reporting progress to obs (which may be nil).
+func Run(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer) (*Result, error) {
+	return run(ctx, r, cfg, obs, nil, "")
+}
+
+// run is Run with an optional Shared and, when inset is not empty, the video
+// at that path drawn over the output as a picture-in-picture.
+func run(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer, sh *Shared, inset string) (res *Result, err error) {
+	res, tmpDir, style, err := encodeInputs(ctx, r, cfg, obs, sh)
+	defer discard(cfg, tmpDir, &err)
+	if err != nil {
+		return res, err
+	}
+	plan := res.Plan
+	total := len(plan.Inputs)
+	intro, outro := cards(cfg)
+	if intro != nil {
+		if res.Intro, err = renderCard(ctx, r, cfg, plan, intro, style, tmpDir); err != nil {
+			return res, err
+		}
+	}
+	if outro != nil {
+		if res.Outro, err = renderCard(ctx, r, cfg, plan, outro, style, tmpDir); err != nil {
+			return res, err
+		}
+	}
+	if err := measureSegments(ctx, r, cfg, res); err != nil {
+		return res, err
+	}
+
+	parts, err := splitTimeline(cfg, res)
+	if err != nil {
+		return res, err
+	}
+	emit(obs, Event{Kind: EventConcatStarted, Total: total})
+	var tmps, outs, subs []string
+	var timelines [][]concat.Chapter
+	res.Duration = 0
+	for k, part := range parts {
+		suffix, out, sub := "", cfg.Output, cfg.Subtitles
+		if len(parts) > 1 {
+			suffix = fmt.Sprintf("_%03d", k+1)
+			out = partPath(out, suffix)
+			if sub != "" {
+				sub = partPath(sub, suffix)
+			}
+			res.Outputs = append(res.Outputs, out)
+		}
+		outTmp, timeline, duration, err := mux(ctx, r, cfg, res, part, style, inset, tmpDir, suffix)
+		if err != nil {
+			return res, err
+		}
+		res.Duration += duration
+		tmps, outs, subs = append(tmps, outTmp), append(outs, out), append(subs, sub)
+		timelines = append(timelines, timeline)
+	}
+
+	if err := publishAll(cfg, res, tmps, outs, subs...); err != nil {
+		return res, err
+	}
+	for k, sub := range subs {
+		if sub == "" {
+			continue
+		}
+		format, err := subtitles.FormatFor(sub)
+		if err != nil {
+			return res, err
+		}
+		if err := writeSubtitles(sub, format, timelines[k]); err != nil {
+			return res, err
+		}
+	}
+
+	finish(cfg, tmpDir, total, obs)
+	return res, nil
+}
+
+// mux joins one part of the timeline into a temp file, adding the chapters,
+// subtitle track, inset, watermark, timecode and audio. suffix tells the files of
+// split parts apart. It returns the file, the part's chapters (relative to
+// its start) and its length.
+func mux(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, res *Result, part []*Segment, style overlay.Style, inset, tmpDir, suffix string) (string, []concat.Chapter, float64, error) {
+	plan := res.Plan
+	var segments []string
+	duration := 0.0
+	for _, seg := range part {
+		segments = append(segments, seg.Path)
+		duration += seg.Duration
+	}
+	limit := partLimit(cfg, part)
+	if limit > 0 {
+		duration = min(duration, limit)
+	}
+	concatPath := filepath.Join(tmpDir, "concat"+suffix+".txt")
+	if err := concat.WriteConcatFile(concatPath, segments); err != nil {
+		return "", nil, 0, err
+	}
+	outTmp := filepath.Join(tmpDir, "out"+suffix+".tmp.mp4")
+	var args []string
+	if part[0].Offset > 0 {
+		// The rest of a clip cut across parts.
+		args = append(args, "-ss", strconv.FormatFloat(part[0].Offset, 'f', -1, 64))
+	}
+	args = append(args,
+		"-f", "concat",
+		"-safe", "0",
+		"-i", concatPath,
+	)
+	timeline := chapters(res, part, limit)
+	var maps []string
+	extra := 0 // inputs after the concat list
+	if cfg.Chapters {
+		chaptersPath := filepath.Join(tmpDir, "chapters"+suffix+".txt")
+		if err := concat.WriteChaptersFile(chaptersPath, timeline); err != nil {
+			return "", nil, 0, err
+		}
+		extra++
+		args = append(args, "-i", chaptersPath)
+		maps = append(maps, "-map_chapters", strconv.Itoa(extra))
+	}
+	if cfg.EmbedSubs {
+		subsPath := filepath.Join(tmpDir, "subtitles"+suffix+".srt")
+		if err := writeSubtitles(subsPath, subtitles.SRT, timeline); err != nil {
+			return "", nil, 0, err
+		}
+		extra++
+		args = append(args, "-i", subsPath)
+		maps = append(maps, "-map", strconv.Itoa(extra)+":s", "-c:s", "mov_text")
+	}
+	var graph []string
+	video := "0:v"
+	chain := "" // filters on the joined video, ending unlabeled
+	if inset != "" {
+		extra++
+		if cfg.InsetTiming == "loop" {
+			args = append(args, "-stream_loop", "-1")
+		}
+		args = append(args, "-i", inset)
+		chain = insetImage(cfg, plan).FilterAs("0:v", strconv.Itoa(extra)+":v", "inset")
+		if cfg.InsetTiming != "loop" {
+			// Without this, overlay keeps drawing the inset's last frame.
+			chain += ":eof_action=pass"
+		}
 	}
-	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+	if plan.Watermark != nil {
+		// Drawn once over the joined video so it stays put across cuts.
+		extra++
+		args = append(args, "-i", plan.Watermark.Path)
+		base := "0:v"
+		if chain != "" {
+			graph = append(graph, chain+"[pip]")
+			base = "pip"
+		}
+		chain = watermark(cfg, plan).Filter(base, strconv.Itoa(extra)+":v")
+	}
+	if cfg.Timecode {
+		if chain == "" {
+			chain = "[0:v]" + overlay.Timecode(style, cfg.TimecodePos)
+		} else {
+			chain += "," + overlay.Timecode(style, cfg.TimecodePos)
+		}
+	}
+	if chain != "" {
+		graph = append(graph, chain+"[v]")
+		video = "[v]"
+	}
+	audio := ""
+	switch {
+	case cfg.SilentAudio:
+		extra++
+		args = append(args, "-f", "lavfi", "-t", formatSeconds(duration), "-i", "anullsrc=r=48000:cl=stereo")
+		audio = strconv.Itoa(extra) + ":a"
+	case cfg.Audio != "":
+		extra++
+		if cfg.AudioLoop {
+			args = append(args, "-stream_loop", "-1")
+		}
+		args = append(args, "-i", plan.Audio)
+		graph = append(graph, "["+strconv.Itoa(extra)+":a]"+backgroundFilter(cfg, duration)+"[bg]")
+		audio = "[bg]"
+		if cfg.AudioPerClip {
+			graph = append(graph, "[0:a][bg]amix=inputs=2:duration=first:normalize=0[a]")
+			audio = "[a]"
+		}
+	case cfg.AudioPerClip:
+		audio = "0:a"
+	}
+	if len(graph) > 0 {
+		args = append(args, "-filter_complex", strings.Join(graph, ";"))
+	}
+	if extra > 0 || len(graph) > 0 || audio != "" {
+		args = append(args, "-map", video)
+		if audio != "" {
+			args = append(args, "-map", audio)
+		}
+		args = append(args, maps...)
+	}
+	args = append(args, encodeArgs(cfg)...)
+	if audio != "" {
+		args = append(args, audioEncodeArgs(cfg)...)
+	} else {
+		args = append(args, "-an")
+	}
+	if limit > 0 {
+		args = append(args, "-t", strconv.FormatFloat(limit, 'f', -1, 64))
+	} else if inset != "" {
+		// A looped inset never ends on its own.
+		args = append(args, "-t", formatSeconds(duration))
+	}
+	args = append(args,
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outTmp,
+	)
+	_, stderr, err := r.Run(ctx, "ffmpeg", args)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("ffmpeg concat failed:\ncmd: %s\n%s", ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+	}
+	return outTmp, timeline, duration, nil
+}
+
+// encodeInputs probes cfg.Inputs and encodes one segment per input, fitted
+// to the canvas, into the temp workspace. It is the first half of every
+// mode; they differ in how the segments are combined. The returned Result is
+// nil if probing failed.
+func encodeInputs(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer, sh *Shared) (*Result, string, overlay.Style, error) {
+	var style overlay.Style
+	plan, err := NewPlan(ctx, r, cfg)
+	if err != nil {
+		return nil, "", style, err
+	}
+	res := &Result{Plan: plan, Output: cfg.Output}
+	total := len(plan.Inputs)
+	for i, in := range plan.Inputs {
+		emit(obs, Event{Kind: EventProbed, Index: i, Total: total, Input: in.Path, Width: in.Width, Height: in.Height})
+	}
+
+	// Temp workspace
+	tmpDir, err := NewWorkspace(cfg)
+	if err != nil {
+		return res, "", style, err
+	}
+	res.Workspace = tmpDir
+
+	if cfg.HasText() {
+		if style, err = textStyle(cfg, tmpDir); err != nil {
+			return res, tmpDir, style, err
+		}
+	}
+	filters, err := segmentFilters(cfg, plan, tmpDir, style)
+	if err != nil {
+		return res, tmpDir, style, err
+	}
+	res.Segments = encodeSegments(ctx, r, cfg, plan, filters, tmpDir, obs, sh)
+	for _, seg := range res.Segments {
+		if seg.Err != nil {
+			return res, tmpDir, style, seg.Err
+		}
+	}
+	return res, tmpDir, style, nil
+}
+
+// publish moves the encoded file at outTmp to cfg.Output, refusing to replace
+// it without --overwrite.
+func publish(cfg *config.Config, res *Result, outTmp string) error {
+	return publishAll(cfg, res, []string{outTmp}, []string{cfg.Output})
+}
+
+// publishAll moves each encoded file in tmps to the matching path in outs. It
+// checks every output and sidecar (empty paths are skipped) before moving
+// anything, so without --overwrite a run replaces all of them or none.
+// res.OutputSize is their total size.
+func publishAll(cfg *config.Config, res *Result, tmps, outs []string, sidecars ...string) error {
+	if !cfg.Overwrite {
+		for _, p := range append(slices.Clip(outs), sidecars...) {
+			if _, err := os.Stat(p); p != "" && err == nil {
+				return fmt.Errorf("output exists: %s (use --overwrite)", p)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
 		return err
 	}
+	res.OutputSize = 0
+	for i, out := range outs {
+		if err := util.AtomicRename(tmps[i], out, true); err != nil {
+			return err
+		}
+		res.OutputSize += fileSize(out)
+	}
+	return nil
+}
 
-	segments := make([]string, len(cfg.Inputs))
-	type job struct {
-		index int
-		input string
+// fileSize returns the size of path, or 0 if it can't be read.
+func fileSize(path string) int64 {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0
 	}
-	jobs := make(chan job, len(cfg.Inputs))
-	for i, in := range cfg.Inputs {
-		jobs <- job{index: i, input: in}
+	return st.Size()
+}
+
+// discard removes the temp workspace of a run that failed with *err, unless
+// --keep-temp; finish does it for runs that succeed.
+func discard(cfg *config.Config, tmpDir string, err *error) {
+	if *err != nil && tmpDir != "" && !cfg.KeepTemp {
+		_ = os.RemoveAll(tmpDir)
+	}
+}
+
+// finish removes the temp workspace unless --keep-temp and reports the run done.
+func finish(cfg *config.Config, tmpDir string, total int, obs Observer) {
+	if !cfg.KeepTemp {
+		_ = os.RemoveAll(tmpDir)
+	} else {
+		emit(obs, Event{Kind: EventTempKept, Total: total, Path: tmpDir})
+	}
+	emit(obs, Event{Kind: EventDone, Total: total, Path: cfg.Output})
+}
+
+// textStyle resolves the font and style for text overlays. Without
+// --font-file or a font FindFont knows, the bundled font is written to tmpDir.
+func textStyle(cfg *config.Config, tmpDir string) (overlay.Style, error) {
+	font := cfg.FontFile
+	if font == "" {
+		if font = overlay.FindFont(); font == "" {
+			var err error
+			if font, err = overlay.BundledFont(tmpDir); err != nil {
+				return overlay.Style{}, err
+			}
+		}
+	} else if _, err := os.Stat(font); err != nil {
+		return overlay.Style{}, fmt.Errorf("font not found: %s", font)
+	}
+	font, err := filepath.Abs(font)
+	if err != nil {
+		return overlay.Style{}, err
+	}
+	return overlay.Style{FontFile: font, Size: cfg.FontSize, Color: cfg.FontColor, Box: cfg.TextBox}, nil
+}
+
+// watermark returns the watermark image overlay, scaled relative to the canvas.
+func watermark(cfg *config.Config, plan *Plan) overlay.Image {
+	im := overlay.Image{Position: cfg.ImagePosition(), Margin: cfg.WatermarkMargin, Opacity: cfg.WatermarkOpacity}
+	if cfg.WatermarkScale > 0 {
+		im.Width = even(int(float64(plan.Width)*cfg.WatermarkScale + 0.5))
+	}
+	return im
+}
+
+// segmentFilters returns the -vf filter for each input, writing the overlay
+// text files it refers to into tmpDir.
+func segmentFilters(cfg *config.Config, plan *Plan, tmpDir string, style overlay.Style) ([]string, error) {
+	var shared []string
+	if cfg.WatermarkText != "" {
+		path := filepath.Join(tmpDir, "watermark.txt")
+		if err := os.WriteFile(path, []byte(cfg.WatermarkText), 0o644); err != nil {
+			return nil, err
+		}
+		shared = append(shared, overlay.TextFile(style, cfg.WatermarkPos, path))
+	}
+	filters := make([]string, len(plan.Inputs))
+	for i, in := range plan.Inputs {
+		overlays := shared
+		if cfg.Captions {
+			path := filepath.Join(tmpDir, fmt.Sprintf("caption_%04d.txt", i))
+			if err := os.WriteFile(path, []byte(in.Title()), 0o644); err != nil {
+				return nil, err
+			}
+			overlays = append([]string{overlay.TextFile(style, cfg.CaptionPos, path)}, shared...)
+		}
+		filters[i] = BuildFilter(cfg, plan.Width, plan.Height, overlays...)
+		if in.Still {
+			if motion := motionFilter(in, cfg.FPS); motion != "" {
+				filters[i] = motion + "," + filters[i]
+			}
+		} else if effects := effectsFilter(in); effects != "" {
+			filters[i] = effects + "," + filters[i]
+		}
+	}
+	return filters, nil
+}
+
+// encodeSegments encodes every input to seg_NNNN.mp4 in tmpDir using
+// cfg.Concurrency workers, applying filters[i] to input i. Failures are
+// recorded in the returned segments.
+func encodeSegments(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, plan *Plan, filters []string, tmpDir string, obs Observer, sh *Shared) []Segment {
+	segments := make([]Segment, len(plan.Inputs))
+	total := len(plan.Inputs)
+	jobs := make(chan int, total)
+	for i := range plan.Inputs {
+		jobs <- i
 	}
 	close(jobs)
 
 	var wg sync.WaitGroup
-	errs := make(chan error, len(cfg.Inputs))
+	var failed atomic.Bool
 	numWorkers := cfg.Concurrency
-	if numWorkers > len(cfg.Inputs) {
-		numWorkers = len(cfg.Inputs)
+	if numWorkers > total {
+		numWorkers = total
 	}
+	numWorkers = max(numWorkers, 1)
 
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for j := range jobs {
-				seg := filepath.Join(tmpDir, fmt.Sprintf("seg_%04d.mp4", j.index))
-				args := []string{
-					"-y", // segments may overwrite if re-run within workspace
-					"-i", j.input,
-					"-vf", BuildFilter(cfg, maxW, maxH),
-					"-an",
-					"-c:v", "libx264",
-					"-preset", cfg.Preset,
-					"-crf", fmt.Sprintf("%d", cfg.CRF),
-					seg,
+			for idx := range jobs {
+				in := plan.Inputs[idx]
+				seg := &segments[idx]
+				seg.Input = in
+				if failed.Load() {
+					seg.Err = fmt.Errorf("skipped %s after an earlier failure", in.Path)
+					continue
+				}
+				emit(obs, Event{Kind: EventSegmentStarted, Index: idx, Total: total, Input: in.Path})
+				start := time.Now()
+				seg.Path = filepath.Join(tmpDir, fmt.Sprintf("seg_%04d.mp4", idx))
+				seg.Decoder = DecoderFFmpeg
+				audioIn, audioOut := segmentAudio(cfg, in)
+				args := []string{"-y"} // segments may overwrite if re-run within workspace
+				if in.Still {
+					args = append(args, stillSource(cfg.FPS, in)...)
+				} else {
+					args = append(args, "-i", in.Path)
 				}
+				args = append(args, audioIn...)
+				args = append(args, "-vf", filters[idx])
+				args = append(args, audioOut...)
+				args = append(args, encodeArgs(cfg)...)
+				args = append(args, seg.Path)
+				// The -vf filter names the overlay text files, not their text.
+				key := sh.segmentKey(in.Path, tmpDir, args, in.Title(), cfg.WatermarkText)
+				if decoder, ok := sh.reuseSegment(key, seg.Path); ok {
+					seg.Decoder, seg.Cached = decoder, true
+					seg.EncodeTime = time.Since(start)
+					emit(obs, Event{Kind: EventSegmentDone, Index: idx, Total: total, Input: in.Path, Path: seg.Path})
+					continue
+				}
+				if err := sh.acquire(ctx); err != nil {
+					seg.Err = err
+					failed.Store(true)
+					continue
+				}
+				// A segment from an earlier run in this workspace may be a
+				// hard link into the cache; writing through it would change
+				// the cached copy.
+				_ = os.Remove(seg.Path)
 				_, stderr, err := r.Run(ctx, "ffmpeg", args)
 				if err != nil {
-					// Fallback to ImageMagick if ffmpeg fails to decode
-					if cfg.MagickBin != "" {
-						if errMagick := decodeWithMagick(ctx, r, cfg, j.input, seg, maxW, maxH); errMagick == nil {
-							segments[j.index] = seg
-							return
+					err = fmt.Errorf("ffmpeg segment failed for %s:\ncmd: %s\n%s", in.Path, ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+					// Fallback to ImageMagick if ffmpeg fails to decode (it cannot read video containers)
+					if cfg.MagickBin != "" && !inputs.IsVideo(in.Path) && !in.Still {
+						emit(obs, Event{Kind: EventFallback, Index: idx, Total: total, Input: in.Path})
+						seg.Decoder = DecoderMagick
+						if errMagick := decodeWithMagick(ctx, r, cfg, in.Path, seg.Path, filters[idx]); errMagick == nil {
+							err = nil
 						}
 					}
-					errs <- fmt.Errorf("ffmpeg segment failed for %s:\ncmd: %s\n%s", j.input, ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
-					return
 				}
-				segments[j.index] = seg
+				sh.release()
+				seg.EncodeTime = time.Since(start)
+				if err != nil {
+					seg.Err = err
+					failed.Store(true)
+					emit(obs, Event{Kind: EventSegmentFailed, Index: idx, Total: total, Input: in.Path, Err: err})
+					continue
+				}
+				sh.storeSegment(key, seg.Path, seg.Decoder)
+				emit(obs, Event{Kind: EventSegmentDone, Index: idx, Total: total, Input: in.Path, Path: seg.Path})
 			}
 		}()
 	}
 	wg.Wait()
-	close(errs)
+	return segments
+}
 
-	if err, ok := <-errs; ok {
-		return err
+// chapters returns one chapter per input segment of part (cards have none),
+// timed from the start of the part, dropping or shortening those past
+// maxDuration (0 means unlimited).
+func chapters(res *Result, part []*Segment, maxDuration float64) []concat.Chapter {
+	var out []concat.Chapter
+	offset := part[0].Start
+	for _, seg := range part {
+		if seg == res.Intro || seg == res.Outro {
+			continue
+		}
+		start := seg.Start - offset
+		end := start + seg.Duration
+		if maxDuration > 0 {
+			if start >= maxDuration {
+				break
+			}
+			end = min(end, maxDuration)
+		}
+		out = append(out, concat.Chapter{Title: seg.Input.Title(), Start: start, End: end})
 	}
+	return out
+}
 
-	concatPath := filepath.Join(tmpDir, "concat.txt")
-	if err := concat.WriteConcatFile(concatPath, segments); err != nil {
-		return err
+// writeSubtitles writes one cue per chapter, showing the clip's title.
+func writeSubtitles(path string, format subtitles.Format, timeline []concat.Chapter) error {
+	cues := make([]subtitles.Cue, len(timeline))
+	for i, ch := range timeline {
+		cues[i] = subtitles.Cue{Start: ch.Start, End: ch.End, Text: ch.Title}
 	}
-	outTmp := filepath.Join(tmpDir, "out.tmp.mp4")
-	args := []string{
-		"-f", "concat",
-		"-safe", "0",
-		"-i", concatPath,
-		"-c:v", "libx264",
-		"-preset", cfg.Preset,
-		"-crf", fmt.Sprintf("%d", cfg.CRF),
-		"-pix_fmt", "yuv420p",
-		"-movflags", "+faststart",
-		"-an",
-		outTmp,
-	}
-	_, stderr, err := r.Run(ctx, "ffmpeg", args)
-	if err != nil {
-		return fmt.Errorf("ffmpeg concat failed:\ncmd: %s\n%s", ffmpeg.PrettyCmd("ffmpeg", args), string(stderr))
+	var buf bytes.Buffer
+	if err := subtitles.Write(&buf, format, cues); err != nil {
+		return err
 	}
+	return util.WriteFile(path, buf.Bytes(), 0o644)
+}
 
-	// Move to final output
-	finalArgs := []string{"-y"}
-	_ = finalArgs // not used; we use Go to move
-	if !cfg.Overwrite {
-		if _, err := os.Stat(cfg.Output); err == nil {
-			return fmt.Errorf("output exists: %s (use --overwrite)", cfg.Output)
-		}
+// timeline returns the segments in output order: the title card, the
+// inputs, then the end card.
+func (res *Result) timeline() []*Segment {
+	var out []*Segment
+	if res.Intro != nil {
+		out = append(out, res.Intro)
 	}
-	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
-		return err
+	for i := range res.Segments {
+		out = append(out, &res.Segments[i])
 	}
-	if err := os.Rename(outTmp, cfg.Output); err != nil {
-		return err
+	if res.Outro != nil {
+		out = append(out, res.Outro)
 	}
+	return out
+}
 
-	// Cleanup unless keep-temp
-	if !cfg.KeepTemp {
-		_ = os.RemoveAll(tmpDir)
-	} else {
-		fmt.Printf("[gif2vid] temp kept at: %s\n", tmpDir)
+// measureSegments probes each encoded segment's duration and lays the
+// segments out on the output timeline.
+func measureSegments(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, res *Result) error {
+	offset := 0.0
+	for _, seg := range res.timeline() {
+		d, err := media.Duration(ctx, r, seg.Path)
+		if err != nil {
+			return err
+		}
+		seg.Duration = d
+		seg.Start = offset
+		offset += d
+	}
+	res.Duration = offset
+	if cfg.MaxDuration > 0 && res.Duration > cfg.MaxDuration {
+		res.Duration = cfg.MaxDuration
 	}
 	return nil
 }
 
-func decodeWithMagick(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, input, output string, targetW, targetH int) error {
+func decodeWithMagick(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, input, output, filter string) error {
 	// 1. Create a temp directory for frames
-	framesDir, err := os.MkdirTemp(cfg.TmpDir, "magick-frames-*")
+	framesDir, err := os.MkdirTemp(filepath.Dir(output), "magick-frames-*")
 	if err != nil {
 		return err
 	}
@@ -185,17 +883,17 @@ func decodeWithMagick(ctx context.Context, r ffmpeg.Runner, cfg *config.Config,
 	}
 
 	// 3. Encode frames using ffmpeg: ffmpeg -f image2 -i framesDir/f_%04d.png ...
+	audioIn, audioOut := segmentAudio(cfg, Input{})
 	ffmpegArgs := []string{
 		"-y",
 		"-framerate", fmt.Sprintf("%d", cfg.FPS),
 		"-i", filepath.Join(framesDir, "f_%04d.png"),
-		"-vf", BuildFilter(cfg, targetW, targetH),
-		"-an",
-		"-c:v", "libx264",
-		"-preset", cfg.Preset,
-		"-crf", fmt.Sprintf("%d", cfg.CRF),
-		output,
 	}
+	ffmpegArgs = append(ffmpegArgs, audioIn...)
+	ffmpegArgs = append(ffmpegArgs, "-vf", filter)
+	ffmpegArgs = append(ffmpegArgs, audioOut...)
+	ffmpegArgs = append(ffmpegArgs, encodeArgs(cfg)...)
+	ffmpegArgs = append(ffmpegArgs, output)
 
 	if _, _, err := r.Run(ctx, "ffmpeg", ffmpegArgs); err != nil {
 		return err
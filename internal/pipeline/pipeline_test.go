@@ -1,9 +1,11 @@
 package pipeline
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/inputs"
 )
 
 func TestEven(t *testing.T) {
@@ -38,3 +40,107 @@ func TestBuildFilter(t *testing.T) {
 		t.Errorf("BuildFilter(...) = %q; want %q", got, want)
 	}
 }
+
+func TestBuildFilterOverlays(t *testing.T) {
+	cfg := &config.Config{FPS: 30, BG: "black"}
+	got := BuildFilter(cfg, 640, 360, "drawtext=a", "drawtext=b")
+	want := "fps=30,scale=640:360:force_original_aspect_ratio=decrease,pad=640:360:(ow-iw)/2:(oh-ih)/2:color=black,drawtext=a,drawtext=b,format=yuv420p"
+	if got != want {
+		t.Errorf("BuildFilter(...) = %q; want %q", got, want)
+	}
+}
+
+func TestEncodeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{"crf default codec", config.Config{Preset: "medium", CRF: 23}, "-c:v libx264 -preset medium -crf 23"},
+		{"bitrate replaces crf", config.Config{Codec: "libx264", Preset: "fast", CRF: 23, Bitrate: "5M"}, "-c:v libx264 -preset fast -b:v 5M"},
+		{"hevc tag", config.Config{Codec: "libx265", Preset: "slow", CRF: 28}, "-c:v libx265 -preset slow -crf 28 -tag:v hvc1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(encodeArgs(&tt.cfg), " ")
+			if got != tt.want {
+				t.Errorf("encodeArgs = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMotionFilter(t *testing.T) {
+	in := Input{Width: 101, Height: 50, Duration: 1.5}
+	for motion, want := range map[string]string{
+		"none":      "",
+		"zoom-out":  "zoompan=z='1.2-0.2*on/45':x='iw/2-(iw/zoom/2)':y='ih/2-(ih/zoom/2)':d=1:s=102x50:fps=30",
+		"pan-left":  "zoompan=z='1.2':x='(iw-iw/zoom)*(1-on/45)':y='ih/2-(ih/zoom/2)':d=1:s=102x50:fps=30",
+		"pan-right": "zoompan=z='1.2':x='(iw-iw/zoom)*on/45':y='ih/2-(ih/zoom/2)':d=1:s=102x50:fps=30",
+	} {
+		in.Motion = motion
+		if got := motionFilter(in, 30); got != want {
+			t.Errorf("motionFilter(%s) = %q; want %q", motion, got, want)
+		}
+	}
+}
+
+func TestEffectsFilter(t *testing.T) {
+	for _, tt := range []struct {
+		in           Input
+		video, audio string
+	}{
+		{Input{Speed: 1}, "", ""},
+		{Input{Speed: 2}, "setpts=(PTS-STARTPTS)/2", "atempo=2"},
+		{Input{Speed: 0.5, Reverse: true}, "reverse,setpts=(PTS-STARTPTS)/0.5", "areverse,atempo=0.5"},
+		{Input{Speed: 3}, "setpts=(PTS-STARTPTS)/3", "atempo=2,atempo=1.5"},
+		{Input{Speed: 2, Start: inputs.Mark{Frame: 10, Frames: true}, Rate: 20},
+			"trim=start_frame=10,setpts=PTS-STARTPTS,setpts=(PTS-STARTPTS)/2",
+			"atrim=start=0.5,asetpts=PTS-STARTPTS,atempo=2"},
+		{Input{Speed: 1, Boomerang: true}, "split[fwd][back];[back]reverse[rev];[fwd][rev]concat=n=2:v=1:a=0", ""},
+	} {
+		if got := effectsFilter(tt.in); got != tt.video {
+			t.Errorf("effectsFilter(%+v) = %q; want %q", tt.in, got, tt.video)
+		}
+		if got := audioEffects(tt.in); got != tt.audio {
+			t.Errorf("audioEffects(%+v) = %q; want %q", tt.in, got, tt.audio)
+		}
+	}
+}
+
+func TestXstackFilter(t *testing.T) {
+	for _, tt := range []struct {
+		n, cols, want int
+	}{{2, 0, 2}, {4, 0, 2}, {7, 0, 3}, {9, 0, 3}, {10, 0, 4}, {3, 5, 3}, {6, 2, 2}} {
+		if got := gridCols(tt.n, tt.cols); got != tt.want {
+			t.Errorf("gridCols(%d, %d) = %d; want %d", tt.n, tt.cols, got, tt.want)
+		}
+	}
+	want := "[0:v][1:v][2:v]xstack=inputs=3:layout=0_0|108_0|0_58:fill=black"
+	if got := xstackFilter(3, 2, 100, 50, 8, "black"); got != want {
+		t.Errorf("xstackFilter = %q; want %q", got, want)
+	}
+}
+
+func TestPairByName(t *testing.T) {
+	a, b, unmatched := pairByName(
+		[]string{"/old/x.gif", "/old/y.gif", "/old/only_old.gif"},
+		[]string{"/new/y.gif", "/new/only_new.gif", "/new/x.gif"},
+	)
+	if strings.Join(a, ",") != "/old/x.gif,/old/y.gif" || strings.Join(b, ",") != "/new/x.gif,/new/y.gif" {
+		t.Errorf("pairs = %v, %v", a, b)
+	}
+	if strings.Join(unmatched, ",") != "/old/only_old.gif,/new/only_new.gif" {
+		t.Errorf("unmatched = %v", unmatched)
+	}
+}
+
+func TestStackFilter(t *testing.T) {
+	want := "[0:v]tpad=stop=-1:stop_mode=clone[s0];[1:v]tpad=stop=-1:stop_mode=clone[s1];[s0][s1]vstack=inputs=2"
+	if got := stackFilter("vertical", [2]string{}); got != want {
+		t.Errorf("stackFilter = %q; want %q", got, want)
+	}
+	if got := stackFilter("horizontal", [2]string{"drawtext=a", "drawtext=b"}); !strings.Contains(got, "stop_mode=clone,drawtext=b[s1];[s0][s1]hstack=inputs=2") {
+		t.Errorf("labeled stackFilter = %q", got)
+	}
+}
@@ -0,0 +1,1084 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+)
+
+// fakeRunner stands in for ffmpeg/ffprobe/ImageMagick. ffprobe reports the
+// dimensions, duration and audio registered for a path (by basename, with
+// defaults) and 40 frames, and every ffmpeg call writes its last argument so later steps
+// find it.
+type fakeRunner struct {
+	mu        sync.Mutex
+	calls     [][]string
+	durations map[string]string // basename -> format duration
+	audio     map[string]bool   // basename -> has an audio stream
+	failOn    func(name string, args []string) bool
+}
+
+func (f *fakeRunner) Run(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, append([]string{name}, args...))
+	f.mu.Unlock()
+	if f.failOn != nil && f.failOn(name, args) {
+		return nil, []byte("boom"), errors.New("exit status 1")
+	}
+	switch name {
+	case "ffprobe":
+		d := "2.000000"
+		if v, ok := f.durations[filepath.Base(args[len(args)-1])]; ok {
+			d = v
+		}
+		streams := `{"codec_type":"video","width":120,"height":80,"nb_read_packets":"40"}`
+		if f.audio[filepath.Base(args[len(args)-1])] {
+			streams += `,{"codec_type":"audio"}`
+		}
+		return []byte(`{"streams":[` + streams + `],"format":{"duration":"` + d + `"}}`), nil, nil
+	case "ffmpeg":
+		out := args[len(args)-1]
+		return nil, nil, os.WriteFile(out, []byte("data"), 0o644)
+	}
+	return nil, nil, nil
+}
+
+// ffmpegCalls returns the ffmpeg invocations, joined for easy matching.
+func (f *fakeRunner) ffmpegCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.calls {
+		if c[0] == "ffmpeg" {
+			out = append(out, strings.Join(c[1:], " "))
+		}
+	}
+	return out
+}
+
+func testConfig(t *testing.T, inputs ...string) *config.Config {
+	t.Helper()
+	tmp := t.TempDir()
+	cfg := config.New()
+	cfg.Output = filepath.Join(tmp, "out.mp4")
+	cfg.TmpDir = filepath.Join(tmp, "work")
+	cfg.Concurrency = 2
+	cfg.Inputs = inputs
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRunResult(t *testing.T) {
+	fr := &fakeRunner{durations: map[string]string{
+		"seg_0000.mp4": "1.500000",
+		"seg_0001.mp4": "2.250000",
+	}}
+	cfg := testConfig(t, "/in/a.gif", "/in/b.webp")
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Duration != 3.75 {
+		t.Errorf("Duration = %v; want 3.75", res.Duration)
+	}
+	if res.Segments[1].Start != 1.5 || res.Segments[1].Duration != 2.25 {
+		t.Errorf("segment 1 = start %v dur %v; want 1.5 / 2.25", res.Segments[1].Start, res.Segments[1].Duration)
+	}
+	for i, seg := range res.Segments {
+		if seg.Decoder != DecoderFFmpeg {
+			t.Errorf("segment %d decoder = %q", i, seg.Decoder)
+		}
+	}
+	if res.OutputSize == 0 {
+		t.Error("OutputSize not recorded")
+	}
+	if _, err := os.Stat(cfg.Output); err != nil {
+		t.Errorf("output missing: %v", err)
+	}
+}
+
+func TestRunMagickFallback(t *testing.T) {
+	fr := &fakeRunner{failOn: func(name string, args []string) bool {
+		// ffmpeg cannot decode the WebP directly
+		return name == "ffmpeg" && args[1] == "-i" && strings.HasSuffix(args[2], ".webp")
+	}}
+	cfg := testConfig(t, "/in/a.webp", "/in/b.gif", "/in/c.gif")
+	cfg.Concurrency = 1
+	cfg.MagickBin = "magick"
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Segments[0].Decoder != DecoderMagick {
+		t.Errorf("segment 0 decoder = %q; want %q", res.Segments[0].Decoder, DecoderMagick)
+	}
+	// A fallback must not stop the worker from encoding the remaining inputs.
+	for i := 1; i < 3; i++ {
+		if res.Segments[i].Decoder != DecoderFFmpeg || res.Segments[i].Path == "" {
+			t.Errorf("segment %d not encoded: %+v", i, res.Segments[i])
+		}
+	}
+}
+
+func TestRunSegmentFailure(t *testing.T) {
+	fr := &fakeRunner{failOn: func(name string, args []string) bool {
+		return name == "ffmpeg" && strings.Contains(strings.Join(args, " "), "bad.gif")
+	}}
+	cfg := testConfig(t, "/in/bad.gif", "/in/b.gif")
+	cfg.Concurrency = 1
+
+	var failed []string
+	obs := ObserverFunc(func(e Event) {
+		if e.Kind == EventSegmentFailed {
+			failed = append(failed, e.Input)
+		}
+	})
+	res, err := Run(context.Background(), fr, cfg, obs)
+	if err == nil || !strings.Contains(err.Error(), "bad.gif") {
+		t.Fatalf("err = %v; want segment failure for bad.gif", err)
+	}
+	if len(failed) != 1 || failed[0] != "/in/bad.gif" {
+		t.Errorf("failed events = %v", failed)
+	}
+	if res == nil || res.Segments[1].Err == nil {
+		t.Error("later segment should be recorded as skipped")
+	}
+}
+
+func TestRunChapters(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"b.gif","caption":"Second clip"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRunner{durations: map[string]string{"seg_0000.mp4": "1.000000", "seg_0001.mp4": "2.000000"}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.Manifest = manifest
+	cfg.Chapters = true
+	cfg.KeepTemp = true
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	calls := fr.ffmpegCalls()
+	final := calls[len(calls)-1]
+	if !strings.Contains(final, "chapters.txt -map 0:v -map_chapters 1") {
+		t.Errorf("final mux does not map chapters: %s", final)
+	}
+	data, err := os.ReadFile(filepath.Join(res.Workspace, "chapters.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"START=1000\nEND=3000\ntitle=Second clip", "END=1000\ntitle=a.gif"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("chapters missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	fr := &fakeRunner{durations: map[string]string{"seg_0000.mp4": "1.000000", "seg_0001.mp4": "2.000000"}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.Chapters = true
+	cfg.EmbedSubs = true
+	cfg.Subtitles = filepath.Join(dir, "out.vtt")
+	cfg.MaxDuration = 2.5
+
+	if _, err := Run(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	calls := fr.ffmpegCalls()
+	final := calls[len(calls)-1]
+	if !strings.Contains(final, "-map 0:v -map_chapters 1 -map 2:s -c:s mov_text") {
+		t.Errorf("final mux does not map chapters and subtitles: %s", final)
+	}
+	data, err := os.ReadFile(cfg.Subtitles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.000\na.gif\n\n2\n00:00:01.000 --> 00:00:02.500\nb.gif\n\n"
+	if string(data) != want {
+		t.Errorf("subtitles =\n%q\nwant\n%q", data, want)
+	}
+
+	// A second run must not clobber the sidecar without --overwrite.
+	if err := os.Remove(cfg.Output); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), fr, cfg, nil); err == nil || !strings.Contains(err.Error(), "out.vtt") {
+		t.Errorf("err = %v; want existing subtitles error", err)
+	}
+}
+
+func TestRunTextOverlays(t *testing.T) {
+	dir := t.TempDir()
+	font := filepath.Join(dir, "font.ttf")
+	for name, data := range map[string]string{"font.ttf": "ttf", "b.txt": "From sidecar\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.Concurrency = 1
+	cfg.Captions = true
+	cfg.WatermarkText = "© crit"
+	cfg.Timecode = true
+	cfg.FontFile = font
+	cfg.KeepTemp = true
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	calls := fr.ffmpegCalls()
+	seg := calls[0]
+	caption := "drawtext=fontfile=" + font + ":textfile=" + filepath.Join(res.Workspace, "caption_0000.txt")
+	watermark := "drawtext=fontfile=" + font + ":textfile=" + filepath.Join(res.Workspace, "watermark.txt")
+	if !strings.Contains(seg, caption) || !strings.Contains(seg, watermark) || !strings.Contains(seg, "format=yuv420p") {
+		t.Errorf("segment filter missing caption or watermark: %s", seg)
+	}
+	if final := calls[len(calls)-1]; !strings.Contains(final, "-filter_complex [0:v]drawtext=fontfile="+font+":text=%{pts") {
+		t.Errorf("final encode missing timecode: %s", final)
+	}
+	for name, want := range map[string]string{"caption_0000.txt": "a.gif", "caption_0001.txt": "From sidecar", "watermark.txt": "© crit"} {
+		data, err := os.ReadFile(filepath.Join(res.Workspace, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+
+	cfg.FontFile = filepath.Join(dir, "missing.ttf")
+	cfg.Overwrite = true
+	if _, err := Run(context.Background(), fr, cfg, nil); err == nil || !strings.Contains(err.Error(), "font not found") {
+		t.Errorf("err = %v; want font not found", err)
+	}
+}
+
+func TestRunWatermark(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	font := filepath.Join(dir, "font.ttf")
+	for _, p := range []string{logo, font} {
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"))
+	cfg.Chapters = true
+	cfg.Timecode = true
+	cfg.FontFile = font
+	cfg.Watermark = logo
+	cfg.WatermarkPos = "bottom-right"
+	cfg.WatermarkOpacity = 0.5
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if wm := res.Plan.Watermark; wm == nil || wm.Width != 120 || wm.Height != 80 {
+		t.Errorf("watermark not probed: %+v", wm)
+	}
+	calls := fr.ffmpegCalls()
+	for _, seg := range calls[:len(calls)-1] {
+		if strings.Contains(seg, "overlay") {
+			t.Errorf("segment encode draws the watermark: %s", seg)
+		}
+	}
+	final := calls[len(calls)-1]
+	want := "-i " + logo + " -filter_complex [2:v]scale=18:-1,format=rgba,colorchannelmixer=aa=0.5[logo];[0:v][logo]overlay=x=W-w-16:y=H-h-16,drawtext=fontfile=" + font
+	if !strings.Contains(final, want) || !strings.Contains(final, "[v] -map [v] -map_chapters 1") {
+		t.Errorf("final encode = %s\nwant watermark overlay %s", final, want)
+	}
+
+	cfg.Watermark = filepath.Join(dir, "missing.png")
+	if _, err := NewPlan(context.Background(), fr, cfg); err == nil || !strings.Contains(err.Error(), "watermark not found") {
+		t.Errorf("err = %v; want watermark not found", err)
+	}
+}
+
+func TestRunCards(t *testing.T) {
+	dir := t.TempDir()
+	bg := filepath.Join(dir, "bg.png")
+	font := filepath.Join(dir, "font.ttf")
+	for _, p := range []string{bg, font} {
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fr := &fakeRunner{durations: map[string]string{"intro.mp4": "3.000000", "seg_0000.mp4": "1.000000", "outro.mp4": "3.000000"}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"))
+	cfg.Intro = "Weekly reel"
+	cfg.Outro = "Thanks"
+	cfg.OutroImage = bg
+	cfg.CardColor = "navy"
+	cfg.FontFile = font
+	cfg.KeepTemp = true
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Intro == nil || res.Outro == nil {
+		t.Fatal("cards missing from result")
+	}
+	if got := res.Segments[0].Start; got != 3 {
+		t.Errorf("input start = %v; want 3 (after the intro)", got)
+	}
+	if res.Outro.Start != 4 || res.Duration != 7 {
+		t.Errorf("outro start = %v, duration = %v; want 4, 7", res.Outro.Start, res.Duration)
+	}
+
+	var intro, outro string
+	for _, c := range fr.ffmpegCalls() {
+		switch {
+		case strings.HasSuffix(c, "intro.mp4"):
+			intro = c
+		case strings.HasSuffix(c, "outro.mp4"):
+			outro = c
+		}
+	}
+	if !strings.Contains(intro, "-f lavfi -i color=c=navy:s=120x80:r=30:d=3") ||
+		!strings.Contains(intro, "textfile="+filepath.Join(res.Workspace, "intro.txt")) ||
+		!strings.Contains(intro, "fontsize=48") || !strings.Contains(intro, "format=yuv420p") {
+		t.Errorf("intro card = %s", intro)
+	}
+	if !strings.Contains(outro, "-loop 1 -framerate 30 -t 3 -i "+bg) || !strings.Contains(outro, "color=navy") {
+		t.Errorf("outro card = %s", outro)
+	}
+
+	data, err := os.ReadFile(filepath.Join(res.Workspace, "concat.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := string(data)
+	i, s, o := strings.Index(list, "intro.mp4"), strings.Index(list, "seg_0000.mp4"), strings.Index(list, "outro.mp4")
+	if i < 0 || !(i < s && s < o) {
+		t.Errorf("concat list not intro, inputs, outro:\n%s", list)
+	}
+}
+
+func TestRunBackgroundAudio(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music.mp3")
+	if err := os.WriteFile(music, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRunner{audio: map[string]bool{"music.mp3": true}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.Audio = music
+	cfg.AudioLoop = true
+	cfg.AudioVolume = 0.5
+	cfg.AudioFadeIn = 1
+	cfg.AudioFadeOut = 2
+
+	if _, err := Run(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	calls := fr.ffmpegCalls()
+	if !strings.Contains(calls[0], " -an ") {
+		t.Errorf("segments should stay silent: %s", calls[0])
+	}
+	final := calls[len(calls)-1]
+	want := "-stream_loop -1 -i " + music + " -filter_complex [1:a]aresample=48000,aformat=channel_layouts=stereo,volume=0.5,apad,atrim=end=4,afade=t=in:st=0:d=1,afade=t=out:st=2:d=2[bg] -map 0:v -map [bg]"
+	if !strings.Contains(final, want) || !strings.Contains(final, "-c:a aac -b:a 192k") || strings.Contains(final, "-an") {
+		t.Errorf("final encode = %s\nwant %s", final, want)
+	}
+
+	// Clip sound is mixed in at full volume; --audio-volume balances it.
+	cfg.AudioPerClip, cfg.Overwrite = true, true
+	fr.calls = nil
+	if _, err := Run(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatalf("Run with clip audio failed: %v", err)
+	}
+	calls = fr.ffmpegCalls()
+	if final := calls[len(calls)-1]; !strings.Contains(final, "[0:a][bg]amix=inputs=2:duration=first:normalize=0[a]") {
+		t.Errorf("final encode = %s", final)
+	}
+	cfg.AudioPerClip = false
+
+	fr.audio = nil
+	if _, err := NewPlan(context.Background(), fr, cfg); err == nil || !strings.Contains(err.Error(), "no audio stream") {
+		t.Errorf("err = %v; want no audio stream", err)
+	}
+}
+
+func TestRunClipAudio(t *testing.T) {
+	fr := &fakeRunner{audio: map[string]bool{"a.mp4": true}}
+	cfg := testConfig(t, "/in/a.mp4", "/in/b.gif")
+	cfg.AudioPerClip = true
+	cfg.AudioCodec = "opus"
+
+	if _, err := Run(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var withSound, silent string
+	for _, c := range fr.ffmpegCalls() {
+		switch {
+		case strings.HasSuffix(c, "seg_0000.mp4"):
+			withSound = c
+		case strings.HasSuffix(c, "seg_0001.mp4"):
+			silent = c
+		}
+	}
+	if !strings.Contains(withSound, "-map 0:v:0 -map 0:a:0 -af "+clipAudioFilter) || strings.Contains(withSound, "anullsrc") {
+		t.Errorf("segment with sound = %s", withSound)
+	}
+	if !strings.Contains(silent, "-i /in/b.gif -f lavfi -i anullsrc=r=48000:cl=stereo -vf") || !strings.Contains(silent, "-map 1:a") {
+		t.Errorf("silent segment = %s", silent)
+	}
+	calls := fr.ffmpegCalls()
+	if final := calls[len(calls)-1]; !strings.Contains(final, "-map 0:v -map 0:a -c:v libx264") || !strings.Contains(final, "-c:a libopus") {
+		t.Errorf("final encode = %s", final)
+	}
+}
+
+func TestRunSilentAudio(t *testing.T) {
+	fr := &fakeRunner{}
+	cfg := testConfig(t, "/in/a.gif")
+	cfg.SilentAudio = true
+	if _, err := Run(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	calls := fr.ffmpegCalls()
+	if final := calls[len(calls)-1]; !strings.Contains(final, "-f lavfi -t 2 -i anullsrc=r=48000:cl=stereo -map 0:v -map 1:a") {
+		t.Errorf("final encode = %s", final)
+	}
+}
+
+func TestRunVideoNoMagickFallback(t *testing.T) {
+	fr := &fakeRunner{failOn: func(name string, args []string) bool {
+		return name == "ffmpeg" && strings.HasSuffix(args[len(args)-1], "seg_0000.mp4")
+	}}
+	cfg := testConfig(t, "/in/clip.webm")
+	cfg.MagickBin = "magick"
+
+	if _, err := Run(context.Background(), fr, cfg, nil); err == nil {
+		t.Fatal("expected segment failure")
+	}
+	for _, c := range fr.calls {
+		if c[0] == "magick" {
+			t.Errorf("ImageMagick used for a video input: %v", c)
+		}
+	}
+}
+
+func TestRunStills(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"shot.png","duration":2,"motion":"zoom-in"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "shot.png"), filepath.Join(dir, "b.jpg"))
+	cfg.Manifest = manifest
+	cfg.StillDur = 4
+	cfg.MagickBin = "magick"
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if in := res.Plan.Inputs[0]; in.Still {
+		t.Error("a GIF is not a still")
+	}
+	if in := res.Plan.Inputs[1]; !in.Still || in.Duration != 2 || in.Motion != "zoom-in" {
+		t.Errorf("manifest still = %+v", in)
+	}
+	if in := res.Plan.Inputs[2]; !in.Still || in.Duration != 4 || in.Motion != "none" {
+		t.Errorf("default still = %+v", in)
+	}
+
+	segs := map[string]string{}
+	for _, c := range fr.ffmpegCalls() {
+		segs[filepath.Base(c[strings.LastIndex(c, " ")+1:])] = c
+	}
+	zoom := "-loop 1 -framerate 30 -t 2 -i " + filepath.Join(dir, "shot.png") +
+		" -vf zoompan=z='1+0.2*on/60':x='iw/2-(iw/zoom/2)':y='ih/2-(ih/zoom/2)':d=1:s=120x80:fps=30,fps=30,scale="
+	if !strings.Contains(segs["seg_0001.mp4"], zoom) {
+		t.Errorf("zoom still = %s\nwant %s", segs["seg_0001.mp4"], zoom)
+	}
+	if got := segs["seg_0002.mp4"]; !strings.Contains(got, "-t 4 -i "+filepath.Join(dir, "b.jpg")+" -vf fps=30,scale=") {
+		t.Errorf("plain still = %s", got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"clips":[{"file":"shot.png","motion":"spin"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Manifest = bad
+	if _, err := NewPlan(context.Background(), fr, cfg); err == nil || !strings.Contains(err.Error(), "unknown motion") {
+		t.Errorf("err = %v; want unknown motion", err)
+	}
+}
+
+func TestRunEffects(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"b.gif","speed":2,"reverse":false}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"), filepath.Join(dir, "c.png"))
+	cfg.Manifest = manifest
+	cfg.Reverse = true
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if in := res.Plan.Inputs[0]; !in.Reverse || in.Speed != 1 {
+		t.Errorf("default input = %+v", in)
+	}
+	if in := res.Plan.Inputs[1]; in.Reverse || in.Speed != 2 {
+		t.Errorf("manifest input = %+v", in)
+	}
+	if in := res.Plan.Inputs[2]; in.Reverse {
+		t.Error("stills are not reversed")
+	}
+
+	segs := map[string]string{}
+	for _, c := range fr.ffmpegCalls() {
+		segs[filepath.Base(c[strings.LastIndex(c, " ")+1:])] = c
+	}
+	if got := segs["seg_0000.mp4"]; !strings.Contains(got, "-vf reverse,fps=30,") {
+		t.Errorf("reversed segment = %s", got)
+	}
+	if got := segs["seg_0001.mp4"]; !strings.Contains(got, "-vf setpts=(PTS-STARTPTS)/2,fps=30,") {
+		t.Errorf("sped-up segment = %s", got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"clips":[{"file":"a.gif","speed":8}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Manifest = bad
+	if _, err := NewPlan(context.Background(), fr, cfg); err == nil {
+		t.Error("expected an error for speed 8")
+	}
+}
+
+func TestRunTrim(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"b.gif","end":"30f"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRunner{audio: map[string]bool{"b.gif": true}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.Manifest = manifest
+	cfg.AudioPerClip = true
+	if err := cfg.TrimStart.Set("0.5"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if in := res.Plan.Inputs[1]; in.Rate != 20 {
+		t.Errorf("rate = %g; want 20 (40 frames in 2s)", in.Rate)
+	}
+	segs := map[string]string{}
+	for _, c := range fr.ffmpegCalls() {
+		segs[filepath.Base(c[strings.LastIndex(c, " ")+1:])] = c
+	}
+	if got := segs["seg_0000.mp4"]; !strings.Contains(got, "-vf trim=start=0.5,setpts=PTS-STARTPTS,fps=30,") {
+		t.Errorf("trimmed segment = %s", got)
+	}
+	got := segs["seg_0001.mp4"]
+	if !strings.Contains(got, "-vf trim=start=0.5:end_frame=30,setpts=PTS-STARTPTS,fps=30,") {
+		t.Errorf("frame-trimmed segment = %s", got)
+	}
+	if !strings.Contains(got, "-af atrim=start=0.5:end=1.5,asetpts=PTS-STARTPTS,") {
+		t.Errorf("frame-trimmed audio = %s", got)
+	}
+
+	for mark, want := range map[string]string{
+		`{"end":"41f"}`:             "trim end 41f is past the end of the clip (2s, 40 frames)",
+		`{"start":2}`:               "trim start 2s is past the end of the clip (2s)",
+		`{"start":"20f","end":0.5}`: "trim end 0.5s must be after the start 20f",
+	} {
+		if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"b.gif",`+mark[1:]+`]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewPlan(context.Background(), fr, cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v; want %q", mark, err, want)
+		}
+	}
+}
+
+func TestRunGrid(t *testing.T) {
+	dir := t.TempDir()
+	fr := &fakeRunner{durations: map[string]string{"seg_0001.mp4": "3.5"}}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"), filepath.Join(dir, "c.gif"))
+
+	res, err := RunGrid(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("RunGrid failed: %v", err)
+	}
+	if res.Duration != 3.5 {
+		t.Errorf("duration = %g; want the longest segment, 3.5", res.Duration)
+	}
+	calls := fr.ffmpegCalls()
+	last := calls[len(calls)-1]
+	for _, want := range []string{
+		"-stream_loop -1 -i " + filepath.Join(res.Workspace, "seg_0000.mp4"),
+		"-filter_complex [0:v][1:v][2:v]xstack=inputs=3:layout=0_0|128_0|0_88:fill=black[v] -map [v]",
+		"-an -t 3.5 ",
+	} {
+		if !strings.Contains(last, want) {
+			t.Errorf("grid call = %s\nwant %s", last, want)
+		}
+	}
+	if _, err := os.Stat(cfg.Output); err != nil {
+		t.Errorf("output not written: %v", err)
+	}
+
+	cfg.GridDuration = 2.5
+	cfg.Overwrite = true
+	if res, err = RunGrid(context.Background(), fr, cfg, nil); err != nil || res.Duration != 2.5 {
+		t.Errorf("fixed duration = %v, %v; want 2.5", res.Duration, err)
+	}
+
+	cfg.Inputs = cfg.Inputs[:1]
+	if _, err := RunGrid(context.Background(), fr, cfg, nil); err == nil {
+		t.Error("expected an error for a single input")
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	dir := t.TempDir()
+	font := filepath.Join(dir, "font.ttf")
+	if err := os.WriteFile(font, []byte("ttf"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old, cur := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	fr := &fakeRunner{durations: map[string]string{"seg_0000.mp4": "1.5", "seg_0002.mp4": "2.5"}}
+	cfg := testConfig(t, filepath.Join(old, "a.gif"), filepath.Join(old, "b.gif"), filepath.Join(old, "gone.gif"))
+	cfg.CompareInputs = []string{filepath.Join(cur, "b.gif"), filepath.Join(cur, "a.gif"), filepath.Join(cur, "added.gif")}
+	cfg.InputDir, cfg.CompareDir = old, cur
+	cfg.Labels = true
+	cfg.LabelB = "after"
+	cfg.FontFile = font
+	cfg.KeepTemp = true
+
+	res, err := RunCompare(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("RunCompare failed: %v", err)
+	}
+	if want := []string{filepath.Join(old, "gone.gif"), filepath.Join(cur, "added.gif")}; strings.Join(res.Unmatched, ",") != strings.Join(want, ",") {
+		t.Errorf("unmatched = %v; want %v", res.Unmatched, want)
+	}
+	// Pair 0 is a.gif (1.5s) against a.gif (2.5s); pair 1 is b.gif at 2s each.
+	if res.Duration != 4.5 || res.Segments[1].Start != 2.5 || res.Segments[3].Start != 2.5 {
+		t.Errorf("duration = %g, starts = %g/%g; want 4.5, 2.5/2.5", res.Duration, res.Segments[1].Start, res.Segments[3].Start)
+	}
+	for i, want := range map[int]string{0: "old", 1: "after"} {
+		if data, _ := os.ReadFile(filepath.Join(res.Workspace, fmt.Sprintf("label_%d.txt", i))); string(data) != want {
+			t.Errorf("label %d = %q; want %q", i, data, want)
+		}
+	}
+	var stacks []string
+	calls := fr.ffmpegCalls()
+	for _, c := range calls {
+		if strings.Contains(c, "hstack") {
+			stacks = append(stacks, c)
+		}
+	}
+	if len(stacks) != 2 || !strings.Contains(stacks[0], "-i "+filepath.Join(res.Workspace, "seg_0000.mp4")+" -i "+filepath.Join(res.Workspace, "seg_0002.mp4")) ||
+		!strings.Contains(stacks[0], "-t 2.5 ") {
+		t.Errorf("stack calls = %v", stacks)
+	}
+	if last := calls[len(calls)-1]; !strings.Contains(last, "-f concat") || !strings.Contains(last, "-c copy") {
+		t.Errorf("concat call = %s", last)
+	}
+
+	cfg.CompareInputs = []string{filepath.Join(cur, "added.gif")}
+	if _, err := RunCompare(context.Background(), fr, cfg, nil); err == nil || !strings.Contains(err.Error(), "no files with matching names") {
+		t.Errorf("err = %v; want no matching names", err)
+	}
+}
+
+func TestRunPIP(t *testing.T) {
+	dir := t.TempDir()
+	var events []EventKind
+	obs := ObserverFunc(func(e Event) { events = append(events, e.Kind) })
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+	cfg.InsetInputs = []string{filepath.Join(dir, "reaction.gif")}
+	cfg.Chapters = true
+	cfg.Concurrency = 1
+
+	res, err := RunPIP(context.Background(), fr, cfg, obs)
+	if err != nil {
+		t.Fatalf("RunPIP failed: %v", err)
+	}
+	if res.Output != cfg.Output || len(res.Plan.Inputs) != 2 || res.Duration != 4 {
+		t.Errorf("result = %+v", res)
+	}
+	calls := fr.ffmpegCalls()
+	// The inset sequence is built in a workspace of pip's own, which is
+	// removed once the main mux has drawn it.
+	pipDir := ""
+	for _, c := range calls {
+		if f := strings.Fields(c); strings.HasSuffix(f[len(f)-1], "out.tmp.mp4") && !strings.Contains(c, res.Workspace) {
+			pipDir = filepath.Dir(filepath.Dir(f[len(f)-1]))
+			if strings.Contains(c, "chapters") {
+				t.Errorf("inset sequence = %s", c)
+			}
+		}
+	}
+	if filepath.Dir(pipDir) != cfg.TmpDir || pipDir == res.Workspace {
+		t.Fatalf("inset workspace = %q; want one of its own in %s", pipDir, cfg.TmpDir)
+	}
+	if _, err := os.Stat(pipDir); !os.IsNotExist(err) {
+		t.Errorf("inset workspace left behind: %v", err)
+	}
+	last := calls[len(calls)-1]
+	want := "-i " + filepath.Join(pipDir, "inset.mp4") +
+		" -filter_complex [2:v]scale=36:-1,format=rgba,pad=iw+8:ih+8:4:4:color=white[inset];[0:v][inset]overlay=x=W-w-16:y=H-h-16:eof_action=pass[v]"
+	if !strings.Contains(last, want) || !strings.Contains(last, "-map_chapters 1") || !strings.Contains(last, "-t 4 ") {
+		t.Errorf("main mux = %s\nwant %s", last, want)
+	}
+	if n := slices.Index(events, EventDone); n != len(events)-1 {
+		t.Errorf("events = %v; want a single done event, last", events)
+	}
+	for _, c := range calls {
+		if strings.Contains(c, "main.mp4") {
+			t.Errorf("main sequence encoded to an intermediate file: %s", c)
+		}
+	}
+
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Watermark = logo
+	cfg.InsetTiming = "loop"
+	cfg.Overwrite = true
+	if _, err := RunPIP(context.Background(), fr, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	calls = fr.ffmpegCalls()
+	last = calls[len(calls)-1]
+	want = "-stream_loop -1 -i " + filepath.Join(cfg.TmpDir, "gif2vid-")
+	graph := " -i " + logo + " -filter_complex [2:v]scale=36:-1,format=rgba,pad=iw+8:ih+8:4:4:color=white[inset];[0:v][inset]overlay=x=W-w-16:y=H-h-16[pip];[3:v]scale=18:-1,format=rgba[logo];[pip][logo]overlay"
+	if !strings.Contains(last, want) || !strings.Contains(last, "inset.mp4"+graph) || strings.Contains(last, "eof_action") {
+		t.Errorf("looped main mux = %s\nwant %s...inset.mp4%s", last, want, graph)
+	}
+
+	// The inset runs across the whole output, so it can't be split.
+	cfg.SplitMaxDuration = 2
+	n := len(fr.ffmpegCalls())
+	if _, err := RunPIP(context.Background(), fr, cfg, nil); !errors.Is(err, config.ErrSplitPIP) || len(fr.ffmpegCalls()) != n {
+		t.Errorf("split pip: %v after %d ffmpeg calls", err, len(fr.ffmpegCalls())-n)
+	}
+}
+
+func TestRunSplit(t *testing.T) {
+	dir := t.TempDir()
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"), filepath.Join(dir, "c.gif"))
+	cfg.Output = filepath.Join(dir, "out", "reel.mp4")
+	cfg.Subtitles = filepath.Join(dir, "out", "reel.srt")
+	cfg.SplitMaxDuration = 4.5
+	cfg.KeepTemp = true
+
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "out", "reel_001.mp4"), filepath.Join(dir, "out", "reel_002.mp4")}
+	if !slices.Equal(res.Outputs, want) || res.Duration != 6 || res.OutputSize != 8 {
+		t.Errorf("outputs = %v, duration %g, size %d; want %v, 6, 8", res.Outputs, res.Duration, res.OutputSize, want)
+	}
+	for _, p := range append(want, filepath.Join(dir, "out", "reel_002.srt")) {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("missing %s", p)
+		}
+	}
+	// The second part's subtitles start from zero.
+	if data, _ := os.ReadFile(filepath.Join(dir, "out", "reel_002.srt")); !strings.Contains(string(data), "00:00:00,000 --> 00:00:02,000\nc.gif") {
+		t.Errorf("part 2 subtitles = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(res.Workspace, "concat_001.txt")); strings.Count(string(data), "file ") != 2 {
+		t.Errorf("part 1 concat = %q", data)
+	}
+
+	// A clip over the limit on its own fails, unless cutting is allowed.
+	fr.durations = map[string]string{"seg_0001.mp4": "6"}
+	cfg.Overwrite = true
+	if _, err := Run(context.Background(), fr, cfg, nil); err == nil || !strings.Contains(err.Error(), "b.gif is 6s long, over --split-max-duration 4.5s") {
+		t.Errorf("err = %v", err)
+	}
+	// Cut, b.gif's first 4.5s get a part of their own, and the rest of it
+	// starts the last part; no part of it is lost.
+	cfg.SplitAllowCut = true
+	if res, err = Run(context.Background(), fr, cfg, nil); err != nil || len(res.Outputs) != 3 || res.Duration != 10 {
+		t.Fatalf("allow cut: %v, outputs %v, duration %g", err, res.Outputs, res.Duration)
+	}
+	calls := fr.ffmpegCalls()
+	if cut := calls[len(calls)-2]; !strings.Contains(cut, "concat_002.txt") || !strings.Contains(cut, "-t 4.5 ") || strings.Contains(cut, "-ss ") {
+		t.Errorf("cut part = %s", cut)
+	}
+	if rest := calls[len(calls)-1]; !strings.HasPrefix(rest, "-ss 4.5 ") || strings.Contains(rest, "-t ") {
+		t.Errorf("rest part = %s", rest)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "out", "reel_003.srt")); !strings.Contains(string(data), "00:00:00,000 --> 00:00:01,500\nb.gif") {
+		t.Errorf("part 3 subtitles = %q", data)
+	}
+
+	// Under --split-max-size, a piece is as long as the clip's bitrate
+	// allows: 3 bytes of each 4-byte segment, then the rest.
+	cfg.SplitMaxDuration, cfg.SplitMaxSize = 0, 3
+	if res, err = Run(context.Background(), fr, cfg, nil); err != nil || len(res.Outputs) != 6 || res.Duration != 10 {
+		t.Fatalf("allow cut by size: %v, outputs %v, duration %g", err, res.Outputs, res.Duration)
+	}
+	calls = fr.ffmpegCalls()
+	if cut := calls[len(calls)-4]; !strings.Contains(cut, "concat_003.txt") || !strings.Contains(cut, "-t 4.5 ") || strings.Contains(cut, "-fs ") {
+		t.Errorf("cut part = %s", cut)
+	}
+	cfg.SplitMaxSize = 0
+
+	cfg.SplitMaxDuration, cfg.SplitEvery = 0, 2
+	fr.durations = nil
+	if res, err = Run(context.Background(), fr, cfg, nil); err != nil || len(res.Outputs) != 2 {
+		t.Errorf("split every 2: %v, outputs %v", err, res.Outputs)
+	}
+}
+
+func TestRunSplitMaxDuration(t *testing.T) {
+	dir := t.TempDir()
+	fr := &fakeRunner{}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"), filepath.Join(dir, "c.gif"), filepath.Join(dir, "d.gif"))
+	cfg.Output = filepath.Join(dir, "reel.mp4")
+	cfg.SplitMaxDuration = 4
+	cfg.MaxDuration = 5
+	cfg.KeepTemp = true
+
+	// --max-duration caps the whole output: c.gif is cut to 1s and ends the
+	// second part, and d.gif, past the limit, is dropped.
+	res, err := Run(context.Background(), fr, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(res.Outputs) != 2 || res.Duration != 5 {
+		t.Errorf("outputs = %v, duration %g; want 2 parts, 5", res.Outputs, res.Duration)
+	}
+	calls := fr.ffmpegCalls()
+	if first := calls[len(calls)-2]; strings.Contains(first, "-t ") {
+		t.Errorf("first part = %s", first)
+	}
+	if second := calls[len(calls)-1]; !strings.Contains(second, "-t 1 ") {
+		t.Errorf("second part = %s", second)
+	}
+	if data, _ := os.ReadFile(filepath.Join(res.Workspace, "concat_002.txt")); strings.Count(string(data), "file ") != 1 {
+		t.Errorf("part 2 concat = %q", data)
+	}
+}
+
+func TestNewPlanManifestTypo(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"clips":[{"file":"missing.gif","caption":"x"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, filepath.Join(dir, "a.gif"))
+	cfg.Manifest = manifest
+	if _, err := NewPlan(context.Background(), &fakeRunner{}, cfg); err == nil {
+		t.Error("expected error for a manifest entry that matches no input")
+	}
+}
+
+func TestSharedRun(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, data string) string {
+		p := filepath.Join(tmp, "in", name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	xa, ya, yb := write("x/a.gif", "same"), write("y/a.gif", "same"), write("y/b.gif", "other")
+	batchDir := filepath.Join(tmp, "batch")
+	sh := NewShared(2, batchDir)
+	job := func(name string, inputs ...string) *config.Config {
+		cfg := testConfig(t, inputs...)
+		cfg.TmpDir = filepath.Join(batchDir, "jobs", name)
+		return cfg
+	}
+	fr := &fakeRunner{}
+	encodes := func() (n int) {
+		for _, c := range fr.ffmpegCalls() {
+			if strings.Contains(c, "seg_") && !strings.Contains(c, "concat") {
+				n++
+			}
+		}
+		return n
+	}
+	probes := func(path string) (n int) {
+		fr.mu.Lock()
+		defer fr.mu.Unlock()
+		for _, c := range fr.calls {
+			if c[0] == "ffprobe" && c[len(c)-1] == path {
+				n++
+			}
+		}
+		return n
+	}
+
+	x := job("x", xa)
+	if _, err := sh.Run(context.Background(), fr, x, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The same contents in another directory reuse the encoded segment.
+	res, err := sh.Run(context.Background(), fr, job("y", ya, yb), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := encodes(); n != 2 {
+		t.Errorf("encoded %d segments; want 2", n)
+	}
+	if res.Segments[0].Decoder != DecoderFFmpeg || res.Duration != 4 {
+		t.Errorf("reused segment = %+v, duration %v", res.Segments[0], res.Duration)
+	}
+
+	before := probes(xa)
+	x.Overwrite = true
+	if _, err := sh.Run(context.Background(), fr, x, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := probes(xa); n != before {
+		t.Errorf("probed %s %d more times; want cached", xa, n-before)
+	}
+	if n := encodes(); n != 2 {
+		t.Errorf("encoded %d segments after a rerun; want 2", n)
+	}
+
+	// Editing a sidecar caption changes the segment, and encoding it again
+	// leaves the cached copy linked into the workspace alone.
+	x.Captions, x.KeepTemp = true, true
+	write("x/a.txt", "first")
+	if _, err := sh.Run(context.Background(), fr, x, nil); err != nil {
+		t.Fatal(err)
+	}
+	write("x/a.txt", "second")
+	if _, err := sh.Run(context.Background(), fr, x, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := encodes(); n != 4 {
+		t.Errorf("encoded %d segments after a caption edit; want 4", n)
+	}
+	cached, _ := filepath.Glob(filepath.Join(batchDir, "cache", "*.mp4"))
+	var files []os.FileInfo
+	for _, c := range cached {
+		st, err := os.Stat(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if os.SameFile(st, f) {
+				t.Errorf("cached segments %s and %s are one file", f.Name(), st.Name())
+			}
+		}
+		files = append(files, st)
+	}
+}
+
+func TestSharedPoolRun(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "a.gif")
+	if err := os.WriteFile(in, []byte("gif"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewSharedPool(1, filepath.Join(tmp, "serve"))
+	fr := &fakeRunner{}
+	for _, name := range []string{"x", "y"} {
+		cfg := testConfig(t, in)
+		cfg.TmpDir = filepath.Join(tmp, "serve", "jobs", name)
+		if _, err := sh.Run(context.Background(), fr, cfg, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for _, c := range fr.ffmpegCalls() {
+		if strings.Contains(c, "seg_0000.mp4") && !strings.Contains(c, "concat") {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("encoded %d segments; want 2, with none cached", n)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "serve", "cache")); !os.IsNotExist(err) {
+		t.Errorf("segment cache created: %v", err)
+	}
+}
+
+func TestRunWorkspace(t *testing.T) {
+	cfg := testConfig(t, "/in/a.gif")
+	other := filepath.Join(cfg.TmpDir, "gif2vid-other", "seg_0000.mp4")
+	if err := os.MkdirAll(filepath.Dir(other), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each run works in a directory of its own and removes only that.
+	res, err := Run(context.Background(), &fakeRunner{}, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if filepath.Dir(res.Workspace) != cfg.TmpDir || !strings.HasPrefix(filepath.Base(res.Workspace), "gif2vid-") {
+		t.Errorf("workspace = %s; want a gif2vid-* directory in %s", res.Workspace, cfg.TmpDir)
+	}
+	if _, err := os.Stat(res.Workspace); !os.IsNotExist(err) {
+		t.Errorf("workspace left behind: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("another run's files were removed: %v", err)
+	}
+}
+
+func TestRunFailureRemovesWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	modes := map[string]func(context.Context, ffmpeg.Runner, *config.Config, Observer) (*Result, error){
+		"run": Run, "grid": RunGrid, "compare": RunCompare,
+	}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			fr := &fakeRunner{failOn: func(name string, args []string) bool {
+				return name == "ffmpeg" && strings.HasSuffix(args[len(args)-1], "seg_0001.mp4")
+			}}
+			cfg := testConfig(t, filepath.Join(dir, "a.gif"), filepath.Join(dir, "b.gif"))
+			cfg.CompareInputs = []string{filepath.Join(dir, "new", "a.gif"), filepath.Join(dir, "new", "b.gif")}
+			cfg.Concurrency = 1
+			if _, err := mode(context.Background(), fr, cfg, nil); err == nil {
+				t.Fatal("expected the segment failure")
+			}
+			if entries, _ := os.ReadDir(cfg.TmpDir); len(entries) != 0 {
+				t.Errorf("left behind in %s: %v", cfg.TmpDir, entries)
+			}
+		})
+	}
+}
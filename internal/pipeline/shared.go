@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/util"
+)
+
+// Shared lets concurrent runs, such as the jobs of a batch, share one pool of
+// segment encoders and reuse each other's probes and encoded segments. Runs
+// keep their temp files under their own TmpDir, which should be inside dir.
+type Shared struct {
+	dir        string
+	workers    chan struct{}
+	noSegments bool // made by NewSharedPool
+
+	mu       sync.Mutex
+	probes   map[string]probe
+	hashes   map[string]string
+	segments map[string]cachedSegment
+}
+
+type probe struct {
+	stdout, stderr []byte
+	err            error
+}
+
+type cachedSegment struct {
+	path    string
+	decoder string
+}
+
+// NewShared returns a Shared running at most workers segment encodes at once
+// and caching segments in dir/cache.
+func NewShared(workers int, dir string) *Shared {
+	return &Shared{
+		dir:      dir,
+		workers:  make(chan struct{}, max(workers, 1)),
+		probes:   map[string]probe{},
+		hashes:   map[string]string{},
+		segments: map[string]cachedSegment{},
+	}
+}
+
+// NewSharedPool returns a Shared like NewShared, but one that doesn't cache
+// segments: for runs that come and go for as long as a server is up, whose
+// cache would only ever grow.
+func NewSharedPool(workers int, dir string) *Shared {
+	s := NewShared(workers, dir)
+	s.noSegments = true
+	return s
+}
+
+// Run is Run using the shared pool and caches.
+func (s *Shared) Run(ctx context.Context, r ffmpeg.Runner, cfg *config.Config, obs Observer) (*Result, error) {
+	return run(ctx, &probeCache{Runner: r, shared: s}, cfg, obs, s, "")
+}
+
+// acquire waits for a free encoder; release returns it. Both are no-ops on a
+// nil Shared, whose runs are bounded only by their own worker count.
+func (s *Shared) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s.workers <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Shared) release() {
+	if s != nil {
+		<-s.workers
+	}
+}
+
+// stamp identifies a version of a file by path, size and modification time.
+func stamp(path string) (string, bool) {
+	st, err := os.Stat(path)
+	if err != nil || st.IsDir() {
+		return "", false
+	}
+	return path + "\x00" + strconv.FormatInt(st.Size(), 10) + "\x00" + strconv.FormatInt(st.ModTime().UnixNano(), 10), true
+}
+
+// probeCache remembers the output of ffprobe calls on source files. Files in
+// the shared workspace are rewritten by every run, so they are never cached.
+type probeCache struct {
+	ffmpeg.Runner
+	shared *Shared
+}
+
+func (p *probeCache) Run(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+	if name != "ffprobe" || len(args) == 0 || strings.HasPrefix(args[len(args)-1], p.shared.dir+string(filepath.Separator)) {
+		return p.Runner.Run(ctx, name, args)
+	}
+	st, ok := stamp(args[len(args)-1])
+	if !ok {
+		return p.Runner.Run(ctx, name, args)
+	}
+	key := strings.Join(args, "\x00") + "\x00" + st
+	p.shared.mu.Lock()
+	c, ok := p.shared.probes[key]
+	p.shared.mu.Unlock()
+	if ok {
+		return c.stdout, c.stderr, c.err
+	}
+	stdout, stderr, err := p.Runner.Run(ctx, name, args)
+	if ctx.Err() == nil {
+		p.shared.mu.Lock()
+		p.shared.probes[key] = probe{stdout, stderr, err}
+		p.shared.mu.Unlock()
+	}
+	return stdout, stderr, err
+}
+
+// segmentKey identifies the segment that args encode from input: the same
+// file contents with the same options give the same segment wherever the file
+// is. Paths in tmpDir differ between runs and are left out, so the overlay
+// texts written there are hashed in their place. It returns "" when input
+// can't be read.
+func (s *Shared) segmentKey(input, tmpDir string, args []string, texts ...string) string {
+	if s == nil || s.noSegments {
+		return ""
+	}
+	st, ok := stamp(input)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	sum, ok := s.hashes[st]
+	s.mu.Unlock()
+	if !ok {
+		f, err := os.Open(input)
+		if err != nil {
+			return ""
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return ""
+		}
+		sum = hex.EncodeToString(h.Sum(nil))
+		s.mu.Lock()
+		s.hashes[st] = sum
+		s.mu.Unlock()
+	}
+	h := sha256.New()
+	io.WriteString(h, sum)
+	// The last argument is the segment path, which differs between runs.
+	for _, a := range args[:len(args)-1] {
+		if a == input {
+			a = "<input>"
+		}
+		io.WriteString(h, "\x00"+strings.ReplaceAll(a, tmpDir, "<tmp>"))
+	}
+	for _, t := range texts {
+		io.WriteString(h, "\x00"+t)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reuseSegment links the segment cached under key to path, reporting
+// whether there was one and its decoder.
+func (s *Shared) reuseSegment(key, path string) (string, bool) {
+	if s == nil || key == "" {
+		return "", false
+	}
+	s.mu.Lock()
+	c, ok := s.segments[key]
+	s.mu.Unlock()
+	if !ok || linkOrCopy(c.path, path) != nil {
+		return "", false
+	}
+	return c.decoder, true
+}
+
+// storeSegment keeps a copy of the segment at path for later runs.
+func (s *Shared) storeSegment(key, path, decoder string) {
+	if s == nil || key == "" {
+		return
+	}
+	dst := filepath.Join(s.dir, "cache", key+".mp4")
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return
+	}
+	if linkOrCopy(path, dst) != nil {
+		return
+	}
+	s.mu.Lock()
+	s.segments[key] = cachedSegment{dst, decoder}
+	s.mu.Unlock()
+}
+
+// linkOrCopy hard-links src to dst, copying when links aren't supported.
+func linkOrCopy(src, dst string) error {
+	_ = os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return util.CopyFile(src, dst, 0o644)
+}
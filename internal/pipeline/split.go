@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/crit/gif2vid/internal/config"
+)
+
+// splitTimeline partitions res.timeline(), cut at --max-duration, into the
+// parts of the output: one part unless a --split-* limit is set. Parts break
+// between clips. Sizes are estimated from the encoded segments, so a muxed
+// part, re-encoded and with audio added, can come out somewhat larger. A clip
+// that alone exceeds a limit is an error, unless --split-allow-cut cuts it
+// into pieces: each full piece gets a part of its own, and the rest of the
+// clip starts the next part.
+func splitTimeline(cfg *config.Config, res *Result) ([][]*Segment, error) {
+	timeline := res.timeline()
+	if cfg.MaxDuration > 0 {
+		n := 0
+		for n < len(timeline) && timeline[n].Start < cfg.MaxDuration {
+			n++
+		}
+		timeline = timeline[:max(n, 1)]
+	}
+	if !cfg.Split() {
+		return [][]*Segment{timeline}, nil
+	}
+	var parts [][]*Segment
+	var cur []*Segment
+	dur, size, count := 0.0, int64(0), 0
+	flush := func() {
+		if len(cur) > 0 {
+			parts = append(parts, cur)
+		}
+		cur, dur, size, count = nil, 0, 0, 0
+	}
+	for _, seg := range timeline {
+		// Cards don't count as inputs, so the outro stays with the last part.
+		card := seg == res.Intro || seg == res.Outro
+		segSize := fileSize(seg.Path)
+		segDur := visible(cfg, seg)
+		if over := overLimit(cfg, segDur, segSize); over != "" {
+			if !cfg.SplitAllowCut {
+				return nil, fmt.Errorf("%s is %s; use --split-allow-cut to cut it", seg.Input.Title(), over)
+			}
+			flush()
+			pieces := cutPieces(cfg, seg, segDur, segSize)
+			for _, p := range pieces[:len(pieces)-1] {
+				parts = append(parts, []*Segment{p})
+			}
+			seg = pieces[len(pieces)-1]
+			segSize = int64(float64(segSize) * seg.Duration / segDur)
+			segDur = seg.Duration
+		}
+		if (cfg.SplitEvery > 0 && !card && count >= cfg.SplitEvery) ||
+			(cfg.SplitMaxDuration > 0 && dur+segDur > cfg.SplitMaxDuration) ||
+			(cfg.SplitMaxSize > 0 && size+segSize > int64(cfg.SplitMaxSize)) {
+			flush()
+		}
+		cur = append(cur, seg)
+		dur += segDur
+		size += segSize
+		if !card {
+			count++
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// overLimit describes how a clip of the given length and size exceeds the
+// split limits on its own, or returns "" if it fits.
+func overLimit(cfg *config.Config, duration float64, size int64) string {
+	if cfg.SplitMaxDuration > 0 && duration > cfg.SplitMaxDuration {
+		return fmt.Sprintf("%ss long, over --split-max-duration %ss", formatSeconds(duration), formatSeconds(cfg.SplitMaxDuration))
+	}
+	if cfg.SplitMaxSize > 0 && size > int64(cfg.SplitMaxSize) {
+		return fmt.Sprintf("%s, over --split-max-size %s", config.ByteSize(size), cfg.SplitMaxSize)
+	}
+	return ""
+}
+
+// cutPieces cuts seg, of which length seconds and size bytes play, into
+// pieces that each fit the split limits, the length of a piece judged from
+// the clip's average bitrate under --split-max-size. Together the pieces
+// cover all of it.
+func cutPieces(cfg *config.Config, seg *Segment, length float64, size int64) []*Segment {
+	piece := length
+	if cfg.SplitMaxDuration > 0 {
+		piece = cfg.SplitMaxDuration
+	}
+	if cfg.SplitMaxSize > 0 && size > 0 {
+		piece = min(piece, length*float64(cfg.SplitMaxSize)/float64(size))
+	}
+	var pieces []*Segment
+	for off := 0.0; off < length; off += piece {
+		p := *seg
+		p.Offset = seg.Offset + off
+		p.Start = seg.Start + off
+		p.Duration = min(piece, length-off)
+		p.Cut = off+p.Duration < seg.Duration
+		pieces = append(pieces, &p)
+	}
+	return pieces
+}
+
+// visible returns how much of seg plays before --max-duration cuts the
+// output.
+func visible(cfg *config.Config, seg *Segment) float64 {
+	if cfg.MaxDuration > 0 {
+		return min(seg.Duration, cfg.MaxDuration-seg.Start)
+	}
+	return seg.Duration
+}
+
+// partLimit returns the -t length limit of a part (0 for none): its length
+// if it ends in a piece cut from a clip, or else the rest of --max-duration
+// if the part runs past it.
+func partLimit(cfg *config.Config, part []*Segment) float64 {
+	last := part[len(part)-1]
+	if last.Cut {
+		return last.Start + last.Duration - part[0].Start
+	}
+	if cfg.MaxDuration > 0 && last.Start+last.Duration > cfg.MaxDuration {
+		return cfg.MaxDuration - part[0].Start
+	}
+	return 0
+}
+
+// IsOutput reports whether path is cfg.Output or one of its split parts, so
+// that an output written into the input directory isn't read back as an input.
+func IsOutput(cfg *config.Config, path string) bool {
+	out, err := filepath.Abs(cfg.Output)
+	if err != nil || cfg.Output == "" {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if path == out {
+		return true
+	}
+	ext := filepath.Ext(out)
+	stem := strings.TrimSuffix(out, ext) + "_"
+	n, ok := strings.CutPrefix(path, stem)
+	if !ok || !strings.HasSuffix(n, ext) {
+		return false
+	}
+	n = strings.TrimSuffix(n, ext)
+	return len(n) == 3 && strings.Trim(n, "0123456789") == ""
+}
+
+// partPath inserts suffix before the extension: out.mp4 → out_001.mp4.
+func partPath(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
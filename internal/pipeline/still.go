@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// stillZoom is how far pan/zoom motions zoom into a still image.
+const stillZoom = 0.2
+
+// stillSource returns the input options that read a still image as a video
+// of in.Duration seconds at the output frame rate.
+func stillSource(fps int, in Input) []string {
+	return []string{"-loop", "1", "-framerate", strconv.Itoa(fps), "-t", formatSeconds(in.Duration), "-i", in.Path}
+}
+
+// motionFilter returns a zoompan filter moving over a still image, or "" for
+// no motion. It keeps the image's own size, so BuildFilter fits the result to
+// the canvas like any other input.
+func motionFilter(in Input, fps int) string {
+	frames := max(int(math.Round(in.Duration*float64(fps))), 1)
+	p := fmt.Sprintf("on/%d", frames) // progress from 0 to 1
+	var z, x, y string
+	center := func(dim string) string { return fmt.Sprintf("%s/2-(%s/zoom/2)", dim, dim) }
+	switch in.Motion {
+	case "zoom-in":
+		z, x, y = fmt.Sprintf("1+%g*%s", stillZoom, p), center("iw"), center("ih")
+	case "zoom-out":
+		z, x, y = fmt.Sprintf("%g-%g*%s", 1+stillZoom, stillZoom, p), center("iw"), center("ih")
+	case "pan-left":
+		z, x, y = fmt.Sprintf("%g", 1+stillZoom), fmt.Sprintf("(iw-iw/zoom)*(1-%s)", p), center("ih")
+	case "pan-right":
+		z, x, y = fmt.Sprintf("%g", 1+stillZoom), fmt.Sprintf("(iw-iw/zoom)*%s", p), center("ih")
+	default:
+		return ""
+	}
+	return fmt.Sprintf("zoompan=z='%s':x='%s':y='%s':d=1:s=%dx%d:fps=%d", z, x, y, even(in.Width), even(in.Height), fps)
+}
@@ -0,0 +1,177 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/pipeline"
+	"github.com/crit/gif2vid/internal/util"
+)
+
+// Report is the JSON summary of a run written by --report and --json.
+type Report struct {
+	Success       bool      `json:"success"`
+	Error         string    `json:"error,omitempty"`
+	Output        string    `json:"output"`
+	Parts         []string  `json:"parts,omitempty"` // the files of a split output
+	OutputSize    int64     `json:"output_size"`
+	Duration      float64   `json:"duration"`
+	Canvas        *Canvas   `json:"canvas,omitempty"`
+	Settings      Settings  `json:"settings"`
+	FFmpegVersion string    `json:"ffmpeg_version,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	Elapsed       float64   `json:"elapsed"`
+	Inputs        []Input   `json:"inputs"`
+	Unmatched     []string  `json:"unmatched,omitempty"` // compare: files with no counterpart
+}
+
+// Canvas is the size every segment was fitted to.
+type Canvas struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Settings are the encode settings that shaped the output.
+type Settings struct {
+	FPS         int     `json:"fps"`
+	CRF         int     `json:"crf"`
+	Preset      string  `json:"preset"`
+	Codec       string  `json:"codec"`
+	Bitrate     string  `json:"bitrate,omitempty"`
+	Background  string  `json:"background"`
+	Profile     string  `json:"profile,omitempty"`
+	MaxDuration float64 `json:"max_duration,omitempty"`
+}
+
+// Input describes one source file and where it landed in the output.
+type Input struct {
+	Path       string  `json:"path"`
+	Caption    string  `json:"caption,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	Decoder    string  `json:"decoder,omitempty"`
+	Fallback   bool    `json:"fallback"`
+	Skipped    bool    `json:"skipped"`
+	Duration   float64 `json:"duration"`
+	Start      float64 `json:"start"`
+	EncodeTime float64 `json:"encode_time"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// New builds a report from a run. res may be nil or partial when the run
+// failed before or during encoding; runErr is the error the run returned.
+func New(cfg *config.Config, res *pipeline.Result, runErr error, ffmpegVersion string, started time.Time) *Report {
+	rep := &Report{
+		Success:       runErr == nil,
+		Output:        cfg.Output,
+		FFmpegVersion: ffmpegVersion,
+		StartedAt:     started.UTC(),
+		Elapsed:       round(time.Since(started).Seconds()),
+		Settings: Settings{
+			FPS:         cfg.FPS,
+			CRF:         cfg.CRF,
+			Preset:      cfg.Preset,
+			Codec:       cfg.Codec,
+			Bitrate:     cfg.Bitrate,
+			Background:  cfg.BG,
+			Profile:     cfg.Profile,
+			MaxDuration: cfg.MaxDuration,
+		},
+		Inputs: []Input{},
+	}
+	if runErr != nil {
+		rep.Error = runErr.Error()
+	}
+	if res != nil {
+		rep.Unmatched = res.Unmatched
+		rep.Parts = res.Outputs
+	}
+	if res == nil || res.Plan == nil {
+		// Nothing was probed; still list the inputs that were requested.
+		for _, p := range cfg.Inputs {
+			rep.Inputs = append(rep.Inputs, Input{Path: p, Skipped: true})
+		}
+		return rep
+	}
+
+	rep.OutputSize = res.OutputSize
+	rep.Duration = round(res.Duration)
+	rep.Canvas = &Canvas{Width: res.Plan.Width, Height: res.Plan.Height}
+	for i, in := range res.Plan.Inputs {
+		ri := Input{Path: in.Path, Caption: in.Caption, Width: in.Width, Height: in.Height, Skipped: true}
+		if i < len(res.Segments) {
+			seg := res.Segments[i]
+			ri.Decoder = seg.Decoder
+			ri.Fallback = seg.Decoder == pipeline.DecoderMagick
+			ri.Skipped = seg.Decoder == ""
+			ri.Duration = round(seg.Duration)
+			ri.Start = round(seg.Start)
+			ri.EncodeTime = round(seg.EncodeTime.Seconds())
+			if seg.Err != nil {
+				ri.Error = seg.Err.Error()
+			}
+		}
+		rep.Inputs = append(rep.Inputs, ri)
+	}
+	return rep
+}
+
+// Encode writes the report as indented JSON.
+func (r *Report) Encode(w io.Writer) error {
+	return encode(w, r)
+}
+
+// WriteFile writes the report to path, creating parent directories.
+func (r *Report) WriteFile(path string) error {
+	return writeFile(path, r)
+}
+
+// Batch is the report of a --batch run, with one report per subdirectory.
+type Batch struct {
+	Success bool      `json:"success"`
+	Failed  int       `json:"failed"`
+	Jobs    []*Report `json:"jobs"`
+}
+
+// NewBatch collects the reports of a batch's jobs.
+func NewBatch(jobs []*Report) *Batch {
+	b := &Batch{Jobs: jobs}
+	for _, j := range jobs {
+		if !j.Success {
+			b.Failed++
+		}
+	}
+	b.Success = b.Failed == 0
+	return b
+}
+
+// Encode writes the batch report as indented JSON.
+func (b *Batch) Encode(w io.Writer) error {
+	return encode(w, b)
+}
+
+// WriteFile writes the batch report to path, creating parent directories.
+func (b *Batch) WriteFile(path string) error {
+	return writeFile(path, b)
+}
+
+func encode(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// round keeps reports readable: millisecond precision is plenty for timestamps.
+func round(sec float64) float64 {
+	return float64(int64(sec*1000+0.5)) / 1000
+}
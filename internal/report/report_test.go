@@ -0,0 +1,67 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/pipeline"
+)
+
+func TestNew(t *testing.T) {
+	cfg := &config.Config{Output: "/out.mp4", FPS: 30, CRF: 23, Preset: "medium", Codec: "libx264", BG: "black"}
+	res := &pipeline.Result{
+		Plan: &pipeline.Plan{Width: 200, Height: 100, Inputs: []pipeline.Input{
+			{Path: "/a.gif", Width: 200, Height: 100},
+			{Path: "/b.webp", Width: 50, Height: 50},
+		}},
+		Segments: []pipeline.Segment{
+			{Decoder: pipeline.DecoderFFmpeg, Duration: 1.5, Start: 0, EncodeTime: 250 * time.Millisecond},
+			{Decoder: pipeline.DecoderMagick, Duration: 2, Start: 1.5},
+		},
+		Duration:   3.5,
+		Output:     "/out.mp4",
+		OutputSize: 1234,
+	}
+
+	rep := New(cfg, res, nil, "ffmpeg version 6.1", time.Now())
+	if !rep.Success || rep.Canvas.Width != 200 || rep.Duration != 3.5 || rep.OutputSize != 1234 {
+		t.Errorf("unexpected report header: %+v", rep)
+	}
+	if len(rep.Inputs) != 2 {
+		t.Fatalf("got %d inputs; want 2", len(rep.Inputs))
+	}
+	b := rep.Inputs[1]
+	if b.Start != 1.5 || b.Duration != 2 || !b.Fallback || b.Decoder != "imagemagick" {
+		t.Errorf("input b = %+v", b)
+	}
+	if rep.Inputs[0].EncodeTime != 0.25 {
+		t.Errorf("encode time = %v; want 0.25", rep.Inputs[0].EncodeTime)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if decoded["ffmpeg_version"] != "ffmpeg version 6.1" {
+		t.Errorf("ffmpeg_version = %v", decoded["ffmpeg_version"])
+	}
+}
+
+func TestNewFailedBeforeProbe(t *testing.T) {
+	cfg := &config.Config{Inputs: []string{"/a.gif"}}
+	rep := New(cfg, nil, errors.New("probe failed"), "", time.Now())
+	if rep.Success || rep.Error != "probe failed" {
+		t.Errorf("report = %+v", rep)
+	}
+	if len(rep.Inputs) != 1 || !rep.Inputs[0].Skipped {
+		t.Errorf("inputs = %+v; want the requested input marked skipped", rep.Inputs)
+	}
+}
@@ -0,0 +1,421 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/inputs"
+)
+
+// Request is the JSON body of POST /jobs, or the "request" field of a
+// multipart submission whose "files" fields are the inputs.
+type Request struct {
+	// InputDir is a server-local directory of inputs, inside one of
+	// Options.AllowDirs. It is not used with uploaded files.
+	InputDir string `json:"input_dir,omitempty"`
+	// Options are build settings by flag name, such as {"fps": 30,
+	// "captions": true}. Relative file paths are resolved in the input
+	// directory; subtitles is a file name among the results.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// serverSettings are decided by the server rather than by jobs.
+var serverSettings = []string{"output", "tmp-dir", "concurrency", "report", "json", "keep-temp", "overwrite", "verbose"}
+
+// pathSettings name files the build reads.
+var pathSettings = []string{"manifest", "font-file", "watermark", "intro-image", "outro-image", "audio"}
+
+// Handler returns the HTTP API:
+//
+//	POST   /jobs                    submit a build (JSON Request, or multipart with files)
+//	GET    /jobs                    list jobs
+//	GET    /jobs/{id}               job status and progress
+//	POST   /jobs/{id}/cancel        cancel a queued or running job
+//	DELETE /jobs/{id}               delete a finished job and its files
+//	GET    /jobs/{id}/output        download the video
+//	GET    /jobs/{id}/files/{name}  download any result, such as a part or subtitles
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.submit)
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.statuses())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, st, ok := s.lookup(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, errNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+	mux.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		st, err := s.cancelJob(r.PathValue("id"))
+		if err != nil {
+			writeError(w, errorCode(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.removeJob(r.PathValue("id")); err != nil {
+			writeError(w, errorCode(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /jobs/{id}/output", s.download)
+	mux.HandleFunc("GET /jobs/{id}/files/{name}", s.download)
+	return mux
+}
+
+// submit creates a job from a POST /jobs request and queues it.
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	if s.opts.MaxUpload > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUpload)
+	}
+	id := newID()
+	dir := filepath.Join(s.opts.Dir, "jobs", id)
+	j, err := s.newJob(r, id, dir)
+	if err == nil {
+		err = s.enqueue(j)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		if j != nil {
+			j.cancel()
+		}
+		writeError(w, errorCode(err), err)
+		return
+	}
+	_, st, _ := s.lookup(id)
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, st)
+}
+
+// newJob reads the request and uploads of a submission and configures its build.
+func (s *Server) newJob(r *http.Request, id, dir string) (*job, error) {
+	var req Request
+	var inputDir string
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		inputDir = filepath.Join(dir, "in")
+		if err := receive(r, inputDir, &req); err != nil {
+			return nil, err
+		}
+		if req.InputDir != "" {
+			return nil, badRequest(errors.New("input_dir can't be combined with uploaded files"))
+		}
+	} else {
+		if err := decodeRequest(r.Body, &req); err != nil {
+			return nil, err
+		}
+		if req.InputDir == "" {
+			return nil, badRequest(errors.New("input_dir or uploaded files are required"))
+		}
+		var err error
+		if inputDir, err = s.allowed(req.InputDir, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	outDir := filepath.Join(dir, "out")
+	cfg, err := s.config(req.Options, inputDir, outDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.InputDir = inputDir
+	cfg.Output = filepath.Join(outDir, "out.mp4")
+	cfg.TmpDir = filepath.Join(dir, "work")
+	cfg.Concurrency = s.opts.Encoders
+	cfg.MagickBin = s.opts.MagickBin
+	if cfg.Inputs, err = inputs.FindFiles(inputDir, cfg.Extensions()); err != nil {
+		return nil, badRequest(err)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	return &job{id: id, dir: dir, cfg: cfg, ctx: ctx, cancel: cancel, state: StateQueued, created: time.Now()}, nil
+}
+
+// config builds the configuration of a job from its options.
+func (s *Server) config(opts map[string]any, inputDir, outDir string) (*config.Config, error) {
+	build := flag.NewFlagSet("", flag.ContinueOnError)
+	config.AddFlags(build)
+	cfg := config.New()
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if build.Lookup(name) == nil || slices.Contains(serverSettings, name) {
+			return nil, badRequest(fmt.Errorf("unknown or reserved setting %q", name))
+		}
+		val, err := optionValue(opts[name])
+		if err != nil {
+			return nil, badRequest(fmt.Errorf("%s: %w", name, err))
+		}
+		switch {
+		case val == "":
+		case slices.Contains(pathSettings, name):
+			if val, err = s.allowed(val, inputDir); err != nil {
+				return nil, err
+			}
+		case name == "notify-url":
+			if err := s.hookAllowed(val); err != nil {
+				return nil, err
+			}
+		case name == "subtitles":
+			if filepath.Base(val) != val || strings.HasPrefix(val, ".") {
+				return nil, badRequest(fmt.Errorf("subtitles must be a file name, not %q", val))
+			}
+			val = filepath.Join(outDir, val)
+		}
+		if err := cfg.Set(name, val); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	if err := cfg.ApplyProfile(); err != nil {
+		return nil, badRequest(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, badRequest(err)
+	}
+	return cfg, nil
+}
+
+// optionValue converts a JSON option to its command-line form. Lists, such
+// as of extensions, are joined with commas.
+func optionValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", fmt.Errorf("unsupported list item %v", e)
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// allowed resolves p, relative to base unless it is absolute, and checks that
+// it lies inside base or one of the allowed directories once symlinks are
+// followed.
+func (s *Server) allowed(p, base string) (string, error) {
+	if !filepath.IsAbs(p) {
+		if base == "" {
+			return "", badRequest(fmt.Errorf("%s: path must be absolute", p))
+		}
+		p = filepath.Join(base, p)
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", badRequest(fmt.Errorf("%s: not found", p))
+	}
+	roots := s.opts.AllowDirs
+	if base != "" {
+		roots = append(slices.Clip(roots), base)
+	}
+	for _, root := range roots {
+		if root, err := filepath.EvalSymlinks(root); err == nil && within(real, root) {
+			return real, nil
+		}
+	}
+	return "", forbidden(fmt.Errorf("%s is outside the allowed directories", p))
+}
+
+// hookAllowed checks that a job's notify-url is on one of the allowed
+// origins, so that jobs can't make the server send requests anywhere else.
+func (s *Server) hookAllowed(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return badRequest(fmt.Errorf("notify-url: %q is not a URL", raw))
+	}
+	for _, a := range s.opts.AllowHooks {
+		if o, err := url.Parse(a); err == nil && strings.EqualFold(o.Scheme, u.Scheme) && strings.EqualFold(o.Host, u.Host) {
+			return nil
+		}
+	}
+	return forbidden(fmt.Errorf("notify-url %s is not on an allowed origin", raw))
+}
+
+// within reports whether p is root or inside it.
+func within(p, root string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// decodeRequest decodes a JSON Request, keeping numbers as written.
+func decodeRequest(r io.Reader, req *Request) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return err
+		}
+		return badRequest(fmt.Errorf("invalid request: %w", err))
+	}
+	return nil
+}
+
+// receive saves the "files" of a multipart submission in dir and decodes its
+// "request" field into req.
+func receive(r *http.Request, dir string, req *Request) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return uploadError(err)
+		}
+		switch part.FormName() {
+		case "request":
+			if err := decodeRequest(part, req); err != nil {
+				return err
+			}
+		case "files":
+			if err := save(part, dir); err != nil {
+				return err
+			}
+			files++
+		default:
+			return badRequest(fmt.Errorf("unexpected form field %q", part.FormName()))
+		}
+	}
+	if files == 0 {
+		return badRequest(errors.New("no files uploaded"))
+	}
+	return nil
+}
+
+// save writes an uploaded file into dir under its own base name.
+func save(part *multipart.Part, dir string) error {
+	name := part.FileName()
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return badRequest(fmt.Errorf("invalid file name %q", name))
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return badRequest(fmt.Errorf("%s uploaded twice", name))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, part)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return uploadError(err)
+	}
+	return nil
+}
+
+// download serves a result of a finished job: the named file, or the video.
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	j, st, ok := s.lookup(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	if st.State != StateSucceeded {
+		writeError(w, http.StatusConflict, fmt.Errorf("job is %s", st.State))
+		return
+	}
+	name := r.PathValue("name")
+	if name == "" {
+		name = filepath.Base(j.cfg.Output)
+	}
+	if !slices.Contains(st.Files, name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no result named %q (have %s)", name, strings.Join(st.Files, ", ")))
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	http.ServeFile(w, r, filepath.Join(j.dir, "out", name))
+}
+
+// httpError carries the status code of a request error.
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+func (e httpError) Unwrap() error { return e.err }
+
+func badRequest(err error) error { return httpError{http.StatusBadRequest, err} }
+func forbidden(err error) error  { return httpError{http.StatusForbidden, err} }
+
+// uploadError reports a failed upload read, which is the client's doing
+// unless the body was simply too large.
+func uploadError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return err
+	}
+	return badRequest(err)
+}
+
+// errorCode picks the HTTP status for err.
+func errorCode(err error) int {
+	var he httpError
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &he):
+		return he.code
+	case errors.As(err, &mbe):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errFinished), errors.Is(err, errActive):
+		return http.StatusConflict
+	case errors.Is(err, errQueueFull):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
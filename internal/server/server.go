@@ -0,0 +1,320 @@
+// Package server runs builds submitted over HTTP. Jobs wait in a bounded
+// queue and run a few at a time, each in its own workspace, sharing one pool
+// of segment encoders.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/crit/gif2vid/internal/config"
+	"github.com/crit/gif2vid/internal/ffmpeg"
+	"github.com/crit/gif2vid/internal/notify"
+	"github.com/crit/gif2vid/internal/pipeline"
+	"github.com/crit/gif2vid/internal/report"
+)
+
+// Job states.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+// Options configures a Server.
+type Options struct {
+	Dir        string        // workspace; every job gets a subdirectory
+	Runner     ffmpeg.Runner // runs ffmpeg and ffprobe
+	MagickBin  string        // ImageMagick fallback; empty if none
+	Workers    int           // jobs run at once (default 1)
+	Encoders   int           // segment encodes at once, across all jobs (default 1)
+	Queue      int           // jobs waiting to run before submissions are refused (default 100)
+	AllowDirs  []string      // server-local directories jobs may read inputs from
+	AllowHooks []string      // origins, such as https://hooks.example.com, a job's notify-url may be on
+	MaxUpload  int64         // largest accepted upload in bytes (0 = unlimited)
+}
+
+// Server queues and runs build jobs. Use Handler to serve its API.
+type Server struct {
+	opts    Options
+	shared  *pipeline.Shared
+	queue   chan *job
+	version string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// job is one submitted build.
+type job struct {
+	id     string
+	dir    string
+	cfg    *config.Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// guarded by Server.mu
+	state     string
+	done      int
+	total     int
+	err       error
+	res       *pipeline.Result
+	created   time.Time
+	started   time.Time
+	finished  time.Time
+	notifyErr error // from delivering the notification, if any
+}
+
+// Status describes a job in API responses.
+type Status struct {
+	ID          string         `json:"id"`
+	State       string         `json:"state"`
+	Done        int            `json:"done"`  // inputs encoded so far
+	Total       int            `json:"total"` // inputs to encode; 0 until probed
+	Error       string         `json:"error,omitempty"`
+	NotifyError string         `json:"notify_error,omitempty"` // failed delivery to the job's notify-url
+	Files       []string       `json:"files,omitempty"`        // results, for GET /jobs/{id}/files/{name}
+	Created     time.Time      `json:"created"`
+	Started     *time.Time     `json:"started,omitempty"`
+	Finished    *time.Time     `json:"finished,omitempty"`
+	Report      *report.Report `json:"report,omitempty"` // once finished
+}
+
+// New returns a Server with its workers running. Close stops them.
+func New(opts Options) (*Server, error) {
+	if opts.Dir == "" || opts.Runner == nil {
+		return nil, errors.New("server: Dir and Runner are required")
+	}
+	opts.Workers = max(opts.Workers, 1)
+	opts.Encoders = max(opts.Encoders, 1)
+	if opts.Queue <= 0 {
+		opts.Queue = 100
+	}
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	opts.Dir = dir
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0o755); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		opts:   opts,
+		shared: pipeline.NewSharedPool(opts.Encoders, dir),
+		queue:  make(chan *job, opts.Queue),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   map[string]*job{},
+	}
+	s.version, _ = ffmpeg.Version(ctx, opts.Runner, "ffmpeg")
+	for range opts.Workers {
+		s.wg.Go(s.work)
+	}
+	return s, nil
+}
+
+// Close cancels every job and waits for the workers to stop and for pending
+// notifications to be delivered. Job files are left in Dir.
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// work runs queued jobs until the server closes.
+func (s *Server) work() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case j := <-s.queue:
+			s.run(j)
+		}
+	}
+}
+
+// run builds j, unless it was canceled while queued.
+func (s *Server) run(j *job) {
+	s.mu.Lock()
+	if j.state != StateQueued {
+		s.mu.Unlock()
+		return
+	}
+	j.state, j.started = StateRunning, time.Now()
+	s.mu.Unlock()
+
+	obs := pipeline.ObserverFunc(func(e pipeline.Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch e.Kind {
+		case pipeline.EventProbed:
+			j.total = e.Total
+		case pipeline.EventSegmentDone:
+			j.done++
+		}
+	})
+	res, err := s.shared.Run(j.ctx, s.opts.Runner, j.cfg, obs)
+	_ = os.RemoveAll(j.cfg.TmpDir)
+	state := StateSucceeded
+	switch {
+	case j.ctx.Err() != nil:
+		state, err = StateCanceled, context.Canceled
+	case err != nil:
+		state = StateFailed
+	}
+
+	s.mu.Lock()
+	j.res, j.err, j.state, j.finished = res, err, state, time.Now()
+	started := j.started
+	s.mu.Unlock()
+	j.cancel()
+	s.notify(j, res, err, started)
+}
+
+// notify sends j's outcome to its webhook, if it has one. Delivery can take
+// a while with retries, so it runs in the background, and Close waits for
+// it. It doesn't take s.mu until delivery is done.
+func (s *Server) notify(j *job, res *pipeline.Result, err error, started time.Time) {
+	hook := notify.New(j.cfg)
+	if hook == nil {
+		return
+	}
+	rep := report.New(j.cfg, res, err, s.version, started)
+	s.wg.Go(func() {
+		ctx, cancel := notify.Detached(s.ctx)
+		defer cancel()
+		nerr := hook.Send(ctx, rep)
+		s.mu.Lock()
+		j.notifyErr = nerr
+		s.mu.Unlock()
+	})
+}
+
+// enqueue adds j to the queue, failing when it is full.
+func (s *Server) enqueue(j *job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.queue <- j:
+	default:
+		return errQueueFull
+	}
+	s.jobs[j.id] = j
+	return nil
+}
+
+var (
+	errQueueFull = errors.New("job queue is full")
+	errNotFound  = errors.New("no such job")
+	errFinished  = errors.New("job has finished")
+	errActive    = errors.New("job is still queued or running")
+)
+
+// cancelJob stops a queued or running job.
+func (s *Server) cancelJob(id string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Status{}, errNotFound
+	}
+	switch j.state {
+	case StateQueued:
+		// The worker will skip j, so the notification is sent from here.
+		j.state, j.err, j.finished = StateCanceled, context.Canceled, time.Now()
+		s.notify(j, nil, j.err, j.created)
+	case StateRunning:
+	default:
+		return s.status(j), errFinished
+	}
+	j.cancel()
+	return s.status(j), nil
+}
+
+// removeJob forgets a finished job and deletes its files.
+func (s *Server) removeJob(id string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return errNotFound
+	case j.state == StateQueued || j.state == StateRunning:
+		s.mu.Unlock()
+		return errActive
+	}
+	delete(s.jobs, id)
+	s.mu.Unlock()
+	return os.RemoveAll(j.dir)
+}
+
+// lookup returns the job with id and its status.
+func (s *Server) lookup(id string) (*job, Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, Status{}, false
+	}
+	return j, s.status(j), true
+}
+
+// statuses lists every job, oldest first.
+func (s *Server) statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, s.status(j))
+	}
+	slices.SortFunc(out, func(a, b Status) int { return a.Created.Compare(b.Created) })
+	return out
+}
+
+// status describes j; s.mu must be held.
+func (s *Server) status(j *job) Status {
+	st := Status{ID: j.id, State: j.state, Done: j.done, Total: j.total, Created: j.created}
+	if j.err != nil {
+		st.Error = j.err.Error()
+	}
+	if j.notifyErr != nil {
+		st.NotifyError = j.notifyErr.Error()
+	}
+	if started := j.started; !started.IsZero() {
+		st.Started = &started
+	}
+	if finished := j.finished; !finished.IsZero() {
+		st.Finished = &finished
+		if j.state != StateCanceled {
+			st.Report = report.New(j.cfg, j.res, j.err, s.version, j.started)
+		}
+	}
+	if j.state == StateSucceeded {
+		entries, _ := os.ReadDir(filepath.Join(j.dir, "out"))
+		for _, e := range entries {
+			st.Files = append(st.Files, e.Name())
+		}
+	}
+	return st
+}
+
+// newID returns a random job ID, hard to guess so that results can only be
+// fetched by whoever submitted the job.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner answers ffprobe with fixed dimensions and makes ffmpeg create
+// its output file (the last argument). While block is open, ffmpeg waits for
+// it to close or for the job to be canceled.
+type fakeRunner struct {
+	mu    sync.Mutex
+	calls [][]string
+	block chan struct{}
+}
+
+func (f *fakeRunner) Run(ctx context.Context, name string, args []string) ([]byte, []byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, append([]string{name}, args...))
+	f.mu.Unlock()
+	switch name {
+	case "ffprobe":
+		return []byte(`{"streams":[{"codec_type":"video","width":100,"height":50}],"format":{"duration":"1.500000"}}`), nil, nil
+	case "ffmpeg":
+		if len(args) == 1 {
+			return []byte("ffmpeg version 7.0"), nil, nil
+		}
+		if f.block != nil {
+			select {
+			case <-f.block:
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+		return nil, nil, os.WriteFile(args[len(args)-1], []byte("mp4"), 0o644)
+	}
+	return nil, nil, errors.New("unexpected command " + name)
+}
+
+func (f *fakeRunner) ran(substr string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if strings.Contains(strings.Join(c, " "), substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestServer(t *testing.T, fr *fakeRunner, opts Options) (*httptest.Server, string) {
+	t.Helper()
+	allowed := t.TempDir()
+	for _, name := range []string{"a.gif", "b.gif"} {
+		if err := os.WriteFile(filepath.Join(allowed, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts.Dir = t.TempDir()
+	opts.Runner = fr
+	opts.AllowDirs = []string{allowed}
+	s, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+	return ts, allowed
+}
+
+func postJSON(t *testing.T, ts *httptest.Server, body string) (int, Status) {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decode(t, resp)
+}
+
+func decode(t *testing.T, resp *http.Response) (int, Status) {
+	t.Helper()
+	defer resp.Body.Close()
+	var st Status
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, st
+}
+
+// waitFor polls the job until it reaches state.
+func waitFor(t *testing.T, ts *httptest.Server, id, state string) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(ts.URL + "/jobs/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, st := decode(t, resp)
+		if st.State == state {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s (%s); want %s", id, st.State, st.Error, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerLocalInputs(t *testing.T) {
+	fr := &fakeRunner{}
+	ts, allowed := newTestServer(t, fr, Options{})
+
+	code, st := postJSON(t, ts, `{"input_dir":"`+allowed+`","options":{"fps":24,"ext":["gif"]}}`)
+	if code != http.StatusAccepted || st.ID == "" {
+		t.Fatalf("submit = %d %+v", code, st)
+	}
+	st = waitFor(t, ts, st.ID, StateSucceeded)
+	if st.Done != 2 || st.Total != 2 || st.Report == nil || len(st.Report.Inputs) != 2 {
+		t.Errorf("status = %+v", st)
+	}
+	if !fr.ran("fps=24") {
+		t.Error("fps option not applied")
+	}
+
+	resp, err := http.Get(ts.URL + "/jobs/" + st.ID + "/output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != "mp4" {
+		t.Errorf("output = %d %q", resp.StatusCode, data)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/"+st.ID, nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete = %v %v", resp, err)
+	}
+	if resp, _ := http.Get(ts.URL + "/jobs/" + st.ID); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted job status = %d", resp.StatusCode)
+	}
+}
+
+func TestServerUpload(t *testing.T) {
+	ts, _ := newTestServer(t, &fakeRunner{}, Options{})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("request", `{"options":{"subtitles":"captions.srt"}}`)
+	for _, name := range []string{"b.gif", "a.gif"} {
+		fw, _ := mw.CreateFormFile("files", name)
+		fw.Write([]byte(name))
+	}
+	mw.Close()
+	resp, err := http.Post(ts.URL+"/jobs", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, st := decode(t, resp)
+	if code != http.StatusAccepted {
+		t.Fatalf("submit = %d %+v", code, st)
+	}
+	st = waitFor(t, ts, st.ID, StateSucceeded)
+	if strings.Join(st.Files, ",") != "captions.srt,out.mp4" {
+		t.Errorf("files = %v", st.Files)
+	}
+	resp, err = http.Get(ts.URL + "/jobs/" + st.ID + "/files/captions.srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(data), "a.gif") {
+		t.Errorf("subtitles = %d %q", resp.StatusCode, data)
+	}
+	if resp, _ := http.Get(ts.URL + "/jobs/" + st.ID + "/files/..%2Fin%2Fa.gif"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("file outside the results = %d", resp.StatusCode)
+	}
+}
+
+func TestServerRejects(t *testing.T) {
+	ts, allowed := newTestServer(t, &fakeRunner{}, Options{})
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"bad json", `{`, http.StatusBadRequest},
+		{"no input", `{}`, http.StatusBadRequest},
+		{"relative dir", `{"input_dir":"in"}`, http.StatusBadRequest},
+		{"outside allowed", `{"input_dir":"` + os.TempDir() + `"}`, http.StatusForbidden},
+		{"reserved setting", `{"input_dir":"` + allowed + `","options":{"output":"/etc/x.mp4"}}`, http.StatusBadRequest},
+		{"unknown setting", `{"input_dir":"` + allowed + `","options":{"colz":3}}`, http.StatusBadRequest},
+		{"invalid value", `{"input_dir":"` + allowed + `","options":{"fps":"fast"}}`, http.StatusBadRequest},
+		{"path escape", `{"input_dir":"` + allowed + `","options":{"watermark":"../logo.png"}}`, http.StatusBadRequest},
+		{"subtitles path", `{"input_dir":"` + allowed + `","options":{"subtitles":"../x.srt"}}`, http.StatusBadRequest},
+		{"notify origin", `{"input_dir":"` + allowed + `","options":{"notify-url":"http://169.254.169.254/latest"}}`, http.StatusForbidden},
+		{"notify url", `{"input_dir":"` + allowed + `","options":{"notify-url":"hooks"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, st := postJSON(t, ts, tt.body); code != tt.code {
+				t.Errorf("code = %d (%+v); want %d", code, st, tt.code)
+			}
+		})
+	}
+}
+
+func TestServerCancel(t *testing.T) {
+	fr := &fakeRunner{block: make(chan struct{})}
+	ts, allowed := newTestServer(t, fr, Options{Workers: 1, Queue: 1})
+	body := `{"input_dir":"` + allowed + `"}`
+
+	_, running := postJSON(t, ts, body)
+	waitFor(t, ts, running.ID, StateRunning)
+	_, queued := postJSON(t, ts, body)
+	if code, _ := postJSON(t, ts, body); code != http.StatusServiceUnavailable {
+		t.Errorf("submit to a full queue = %d", code)
+	}
+
+	for _, id := range []string{queued.ID, running.ID} {
+		resp, err := http.Post(ts.URL+"/jobs/"+id+"/cancel", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, _ := decode(t, resp); code != http.StatusOK {
+			t.Errorf("cancel %s = %d", id, code)
+		}
+		waitFor(t, ts, id, StateCanceled)
+	}
+	resp, _ := http.Post(ts.URL+"/jobs/"+running.ID+"/cancel", "", nil)
+	if code, _ := decode(t, resp); code != http.StatusConflict {
+		t.Errorf("cancel a finished job = %d", code)
+	}
+	if resp, _ := http.Get(ts.URL + "/jobs/" + running.ID + "/output"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("output of a canceled job = %d", resp.StatusCode)
+	}
+}
+
+func TestServerNotify(t *testing.T) {
+	release := make(chan struct{})
+	got := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		got <- string(body)
+	}))
+	defer hook.Close()
+	ts, allowed := newTestServer(t, &fakeRunner{}, Options{Workers: 1, AllowHooks: []string{hook.URL}})
+
+	code, first := postJSON(t, ts, `{"input_dir":"`+allowed+`","options":{"notify-url":"`+hook.URL+`/done"}}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("submit = %d (%+v)", code, first)
+	}
+	waitFor(t, ts, first.ID, StateSucceeded)
+	// The only worker runs the next job while the notification is pending.
+	_, second := postJSON(t, ts, `{"input_dir":"`+allowed+`"}`)
+	waitFor(t, ts, second.ID, StateSucceeded)
+
+	close(release)
+	select {
+	case body := <-got:
+		if !strings.Contains(body, `"success":true`) {
+			t.Errorf("notification = %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+}
+
+func TestServerNotifyCanceledWhileQueued(t *testing.T) {
+	got := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- string(body)
+	}))
+	defer hook.Close()
+	fr := &fakeRunner{block: make(chan struct{})}
+	defer close(fr.block)
+	ts, allowed := newTestServer(t, fr, Options{Workers: 1, AllowHooks: []string{hook.URL}})
+
+	_, running := postJSON(t, ts, `{"input_dir":"`+allowed+`"}`)
+	waitFor(t, ts, running.ID, StateRunning)
+	_, queued := postJSON(t, ts, `{"input_dir":"`+allowed+`","options":{"notify-url":"`+hook.URL+`/done"}}`)
+	resp, err := http.Post(ts.URL+"/jobs/"+queued.ID+"/cancel", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := decode(t, resp); code != http.StatusOK {
+		t.Fatalf("cancel = %d", code)
+	}
+
+	// The worker is still busy with the first job; it never runs this one.
+	select {
+	case body := <-got:
+		if !strings.Contains(body, `"success":false`) || !strings.Contains(body, "canceled") {
+			t.Errorf("notification = %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+}
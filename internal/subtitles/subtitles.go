@@ -0,0 +1,77 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Cue is a caption shown from Start to End, in seconds.
+type Cue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// Format is a subtitle file format.
+type Format string
+
+const (
+	VTT Format = "vtt"
+	SRT Format = "srt"
+)
+
+// FormatFor picks the format from a file extension (.vtt or .srt).
+func FormatFor(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".vtt":
+		return VTT, nil
+	case ".srt":
+		return SRT, nil
+	}
+	return "", fmt.Errorf("unsupported subtitle format %q (use .vtt or .srt)", filepath.Ext(path))
+}
+
+// Write renders cues in the given format.
+func Write(w io.Writer, f Format, cues []Cue) error {
+	if f == VTT {
+		if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+			return err
+		}
+	}
+	for i, c := range cues {
+		var err error
+		if f == VTT {
+			_, err = fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, '.'), timestamp(c.End, '.'), escapeText(f, c.Text))
+		} else {
+			_, err = fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, ','), timestamp(c.End, ','), escapeText(f, c.Text))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timestamp formats seconds as HH:MM:SS.mmm (VTT) or HH:MM:SS,mmm (SRT).
+func timestamp(sec float64, sep byte) string {
+	ms := int64(sec*1000 + 0.5)
+	h := ms / 3_600_000
+	m := ms / 60_000 % 60
+	s := ms / 1000 % 60
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", h, m, s, sep, ms%1000)
+}
+
+// escapeText keeps a caption on its own cue: blank lines end a cue in both
+// formats, and VTT treats '<' and '&' as markup.
+func escapeText(f Format, s string) string {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "\r", ""))
+	for strings.Contains(s, "\n\n") {
+		s = strings.ReplaceAll(s, "\n\n", "\n")
+	}
+	if f == VTT {
+		s = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "-->", "--&gt;").Replace(s)
+	}
+	return s
+}
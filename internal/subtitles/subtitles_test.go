@@ -0,0 +1,65 @@
+package subtitles
+
+import (
+	"bytes"
+	"testing"
+)
+
+var cues = []Cue{
+	{Start: 0, End: 1.5, Text: "a.gif"},
+	{Start: 1.5, End: 3723.25, Text: "Tom & Jerry <3\n\nagain"},
+}
+
+func TestWriteVTT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, VTT, cues); err != nil {
+		t.Fatal(err)
+	}
+	want := `WEBVTT
+
+1
+00:00:00.000 --> 00:00:01.500
+a.gif
+
+2
+00:00:01.500 --> 01:02:03.250
+Tom &amp; Jerry &lt;3
+again
+
+`
+	if buf.String() != want {
+		t.Errorf("VTT =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestWriteSRT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, SRT, cues); err != nil {
+		t.Fatal(err)
+	}
+	want := `1
+00:00:00,000 --> 00:00:01,500
+a.gif
+
+2
+00:00:01,500 --> 01:02:03,250
+Tom & Jerry <3
+again
+
+`
+	if buf.String() != want {
+		t.Errorf("SRT =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	if f, err := FormatFor("out.VTT"); err != nil || f != VTT {
+		t.Errorf("FormatFor(out.VTT) = %q, %v", f, err)
+	}
+	if f, err := FormatFor("out.srt"); err != nil || f != SRT {
+		t.Errorf("FormatFor(out.srt) = %q, %v", f, err)
+	}
+	if _, err := FormatFor("out.ass"); err == nil {
+		t.Error("expected error for .ass")
+	}
+}
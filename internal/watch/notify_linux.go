@@ -0,0 +1,54 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+// inotify watches one directory. The descriptor is non-blocking, so reads go
+// through the runtime poller and Close interrupts them.
+type inotify struct {
+	f *os.File
+}
+
+func newNotifier(dir string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &inotify{os.NewFile(uintptr(fd), "inotify")}, nil
+}
+
+func (n *inotify) Read(ctx context.Context, names chan<- string) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		k, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= k; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+			if ev.Mask&syscall.IN_ISDIR != 0 {
+				continue
+			}
+			// An overflow loses events, so it counts as an unknown change.
+			send(ctx, names, string(bytes.TrimRight(name, "\x00")))
+		}
+	}
+}
+
+func (n *inotify) Close() error {
+	return n.f.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+func newNotifier(dir string) (notifier, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,156 @@
+// Package watch reports changes to the files of a directory, using inotify
+// where it is available and polling elsewhere.
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Options configures Changes.
+type Options struct {
+	// Match reports whether a change to the named file (a base name) is of
+	// interest. Nil matches every file.
+	Match func(name string) bool
+	// Debounce is how long the directory must stay quiet before a burst of
+	// changes is reported as one.
+	Debounce time.Duration
+	// Interval is how often the directory is scanned when polling.
+	Interval time.Duration
+	// Poll scans the directory even where inotify is available.
+	Poll bool
+}
+
+// Changes watches dir until ctx is done, sending one value on the returned
+// channel after each burst of matching changes settles. The channel is
+// closed when watching stops.
+func Changes(ctx context.Context, dir string, opts Options) (<-chan struct{}, error) {
+	if _, err := os.ReadDir(dir); err != nil {
+		return nil, err
+	}
+	if opts.Match == nil {
+		opts.Match = func(string) bool { return true }
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	raw := make(chan string, 64)
+	var n notifier
+	if !opts.Poll {
+		n, _ = newNotifier(dir)
+	}
+	if n != nil {
+		go func() {
+			<-ctx.Done()
+			n.Close()
+		}()
+		go func() {
+			defer close(raw)
+			n.Read(ctx, raw)
+		}()
+	} else {
+		go func() {
+			defer close(raw)
+			poll(ctx, dir, opts.Interval, raw)
+		}()
+	}
+
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		debounce(ctx, raw, opts, out)
+	}()
+	return out, nil
+}
+
+// notifier sends the names of changed files until it is closed. An empty
+// name means a change to an unknown file.
+type notifier interface {
+	Read(ctx context.Context, names chan<- string)
+	Close() error
+}
+
+// debounce sends on out once no matching name has arrived on raw for
+// opts.Debounce. A value still waiting on out absorbs later bursts.
+func debounce(ctx context.Context, raw <-chan string, opts Options, out chan<- struct{}) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name, ok := <-raw:
+			if !ok {
+				return
+			}
+			if name != "" && !opts.Match(name) {
+				continue
+			}
+			pending = true
+			timer.Reset(opts.Debounce)
+		case <-timer.C:
+			if pending {
+				pending = false
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// poll scans dir every interval, sending the names of files that appeared,
+// disappeared or changed size or modification time.
+func poll(ctx context.Context, dir string, interval time.Duration, names chan<- string) {
+	prev := scan(dir)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cur := scan(dir)
+		for name, st := range cur {
+			if old, ok := prev[name]; !ok || old != st {
+				send(ctx, names, name)
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				send(ctx, names, name)
+			}
+		}
+		prev = cur
+	}
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// scan returns the state of the files in dir; unreadable entries are left out.
+func scan(dir string) map[string]fileState {
+	entries, _ := os.ReadDir(dir)
+	files := make(map[string]fileState, len(entries))
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			files[e.Name()] = fileState{info.Size(), info.ModTime()}
+		}
+	}
+	return files
+}
+
+// send sends name unless ctx is done first.
+func send(ctx context.Context, names chan<- string, name string) {
+	select {
+	case names <- name:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		poll bool
+	}{{"notify", false}, {"poll", true}} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			changes, err := Changes(ctx, dir, Options{
+				Match:    func(name string) bool { return strings.HasSuffix(name, ".gif") },
+				Debounce: 50 * time.Millisecond,
+				Interval: 10 * time.Millisecond,
+				Poll:     tt.poll,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			write := func(name string) {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A burst of writes is reported once.
+			for _, name := range []string{"a.gif", "b.gif", "c.gif"} {
+				write(name)
+				time.Sleep(5 * time.Millisecond)
+			}
+			select {
+			case <-changes:
+			case <-time.After(2 * time.Second):
+				t.Fatal("no change reported")
+			}
+			select {
+			case <-changes:
+				t.Fatal("burst reported twice")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			// Files that don't match are ignored.
+			write("notes.md")
+			select {
+			case <-changes:
+				t.Fatal("change to an unmatched file reported")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			cancel()
+			select {
+			case _, ok := <-changes:
+				if ok {
+					t.Error("change reported after cancel")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("channel not closed after cancel")
+			}
+		})
+	}
+}
+
+func TestChangesMissingDir(t *testing.T) {
+	if _, err := Changes(context.Background(), filepath.Join(t.TempDir(), "missing"), Options{}); err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}